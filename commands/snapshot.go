@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/InspectorGadget/aws-compose-service/controllers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/spf13/cobra"
+)
+
+// NewSnapshotCommand wires "aws-compose-service snapshot create|list|restore|delete".
+func NewSnapshotCommand(ctx context.Context, opt *structs.Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Manage snapshots of a service's RDS instance",
+		Long:  `snapshot checkpoints the Compose service's RDS instance and rolls it back. Only snapshots tagged with the service's project / service ownership tags are listed, restored or deleted.`,
+	}
+
+	// Service selection (also available globally)
+	cmd.PersistentFlags().StringVar(&opt.Service, "service", opt.Service, "AWS service to manage (rds)")
+	cmd.PersistentFlags().StringVar(&opt.Region, "region", "ap-southeast-1", "AWS region")
+
+	newAction := func(action, short string) *cobra.Command {
+		return &cobra.Command{
+			Use:   action,
+			Short: short,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				return controllers.ParseSnapshotCommand(ctx, *opt, action)
+			},
+		}
+	}
+
+	create := newAction("create", "Take a snapshot of the instance")
+	create.Flags().StringVar(&opt.SnapshotID, "snapshot_id", "", "Snapshot identifier (default: <name>-<timestamp>)")
+
+	list := newAction("list", "List the instance's snapshots, newest first")
+
+	restore := newAction("restore", "Replace the instance with one restored from a snapshot")
+	restore.Flags().StringVar(&opt.SnapshotID, "snapshot_id", "", "Snapshot to restore")
+	restore.Flags().BoolVar(&opt.KeepPrevious, "keep_previous", false, "Keep the replaced instance as <name>-pre-restore-<timestamp>")
+
+	del := newAction("delete", "Delete a snapshot")
+	del.Flags().StringVar(&opt.SnapshotID, "snapshot_id", "", "Snapshot to delete")
+
+	cmd.AddCommand(create, list, restore, del)
+
+	return cmd
+}
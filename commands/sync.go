@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+
+	"github.com/InspectorGadget/aws-compose-service/controllers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/spf13/cobra"
+)
+
+// NewSyncCommand wires "aws-compose-service sync".
+func NewSyncCommand(ctx context.Context, opt *structs.Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Sync a local directory into a service's S3 bucket",
+		Long:  `sync uploads seed_dir into the service's S3 bucket, skipping unchanged files. With --watch it keeps running and uploads or deletes objects as files change, printing one JSONL event per change.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			return controllers.ParseSyncCommand(ctx, *opt)
+		},
+	}
+
+	// Service selection (also available globally)
+	cmd.Flags().StringVar(&opt.Service, "service", opt.Service, "AWS service to manage (s3)")
+
+	cmd.Flags().StringVar(&opt.Region, "region", "ap-southeast-1", "AWS region")
+	cmd.Flags().StringVar(&opt.BucketName, "bucket_name", "", "S3 bucket name (optional; will be derived if empty)")
+	cmd.Flags().StringVar(&opt.SeedDir, "seed_dir", "", "Local directory to sync into the bucket")
+	cmd.Flags().StringVar(&opt.SeedPrefix, "seed_prefix", "", "Key prefix for synced files")
+	cmd.Flags().BoolVar(&opt.SyncWatch, "watch", false, "Keep syncing changes until interrupted")
+	cmd.Flags().IntVar(&opt.SyncDebounce, "debounce", 300, "Quiet period before syncing a burst of changes (milliseconds)")
+
+	return cmd
+}
@@ -0,0 +1,43 @@
+package commands
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+
+	"github.com/InspectorGadget/aws-compose-service/controllers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/spf13/cobra"
+)
+
+// NewTunnelCommand wires the hidden "aws-compose-service tunnel" helper that
+// "up" launches in the background to reach private RDS instances over SSH.
+func NewTunnelCommand(ctx context.Context, opt *structs.Options) *cobra.Command {
+	var listen string
+	var remote string
+
+	cmd := &cobra.Command{
+		Use:    "tunnel",
+		Short:  "Forward a local port to an RDS endpoint through an SSH bastion",
+		Long:   `tunnel runs in the background until "down" stops it. It is started automatically by "up" when tunnel_host is set.`,
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			return controllers.RunTunnel(ctx, *opt, listen, remote)
+		},
+	}
+
+	cmd.Flags().StringVar(&opt.TunnelHost, "tunnel_host", "", "SSH bastion host")
+	cmd.Flags().IntVar(&opt.TunnelPort, "tunnel_port", 22, "SSH bastion port")
+	cmd.Flags().StringVar(&opt.TunnelUser, "tunnel_user", "ec2-user", "SSH user on the bastion")
+	cmd.Flags().StringVar(&opt.TunnelKey, "tunnel_key", "", "Path to the SSH private key")
+	cmd.Flags().StringVar(&opt.TunnelKnownHosts, "tunnel_known_hosts", "", "known_hosts file (default: ~/.ssh/known_hosts)")
+	cmd.Flags().BoolVar(&opt.TunnelInsecureHostKey, "tunnel_insecure_host_key", false, "Skip bastion host key verification")
+
+	cmd.Flags().StringVar(&listen, "listen", "127.0.0.1:5432", "Local address to listen on")
+	cmd.Flags().StringVar(&remote, "remote", "", "Remote host:port to forward to")
+
+	return cmd
+}
@@ -13,6 +13,15 @@ import (
 func NewUpCommand(ctx context.Context, opt *structs.Options) *cobra.Command {
 	var subnetIDs string
 	var sgIDs string
+	var logExports string
+	var seedFiles string
+	var events string
+	var lifecycleTransitions string
+	var lifecycleTags string
+	var corsOrigins string
+	var corsMethods string
+	var corsHeaders string
+	var corsExposeHeaders string
 
 	cmd := &cobra.Command{
 		Use:   "up",
@@ -22,6 +31,15 @@ func NewUpCommand(ctx context.Context, opt *structs.Options) *cobra.Command {
 			// Normalise slice fields just before execution.
 			opt.SubnetIDs = helpers.SplitAndTrim(subnetIDs)
 			opt.SecurityGroupIDs = helpers.SplitAndTrim(sgIDs)
+			opt.LogExports = helpers.SplitAndTrim(logExports)
+			opt.SeedFiles = helpers.SplitAndTrim(seedFiles)
+			opt.Events = helpers.SplitAndTrim(events)
+			opt.LifecycleTransitions = helpers.SplitAndTrim(lifecycleTransitions)
+			opt.LifecycleTags = helpers.SplitAndTrim(lifecycleTags)
+			opt.CORSAllowedOrigins = helpers.SplitAndTrim(corsOrigins)
+			opt.CORSAllowedMethods = helpers.SplitAndTrim(corsMethods)
+			opt.CORSAllowedHeaders = helpers.SplitAndTrim(corsHeaders)
+			opt.CORSExposeHeaders = helpers.SplitAndTrim(corsExposeHeaders)
 
 			return controllers.ParseUpCommand(ctx, *opt)
 		},
@@ -32,13 +50,19 @@ func NewUpCommand(ctx context.Context, opt *structs.Options) *cobra.Command {
 
 	// RDS-related options
 	cmd.Flags().StringVar(&opt.Region, "region", "ap-southeast-1", "AWS region")
-	cmd.Flags().StringVar(&opt.Engine, "engine", "postgres", "Database engine")
+	cmd.Flags().StringVar(&opt.Engine, "engine", "postgres", "Database engine: postgres, mysql, mariadb, sqlserver-ex|web|se|ee, oracle-se2|ee[-cdb], db2-se|ae")
 	cmd.Flags().StringVar(&opt.EngineVersion, "engine_version", "", "Database engine version")
 
+	cmd.Flags().StringVar(&opt.LicenseModel, "license_model", "", "RDS license model (default: the engine's first supported model)")
+	cmd.Flags().StringVar(&opt.DBParameterGroup, "db_parameter_group", "", "DB parameter group for a new instance (required for Db2 bring-your-own-license)")
+	cmd.Flags().StringVar(&opt.OracleConnectBy, "oracle_connect_by", "service", "Oracle DSN format: service (service name) or sid")
+
 	cmd.Flags().StringVar(&opt.InstanceClass, "instance_class", "db.t3.micro", "RDS instance class")
 	cmd.Flags().IntVar(&opt.AllocatedStorage, "allocated_storage", 20, "Allocated storage (GiB)")
+	cmd.Flags().BoolVar(&opt.ValidateInstanceClass, "validate_instance_class", true, "Validate instance_class / engine / multi_az before creating")
+	cmd.Flags().StringVar(&opt.OrderableSnapshot, "orderable_snapshot", "", "Offline orderable-options snapshot (JSON) to validate against")
 
-	cmd.Flags().StringVar(&opt.DBName, "db_name", "app", "Database name")
+	cmd.Flags().StringVar(&opt.DBName, "db_name", "", "Database name (default: app, or the service name with shared_instance)")
 	cmd.Flags().StringVar(&opt.Username, "username", "admin", "Master username")
 	cmd.Flags().StringVar(&opt.Password, "password", "password", "Master password")
 
@@ -48,8 +72,91 @@ func NewUpCommand(ctx context.Context, opt *structs.Options) *cobra.Command {
 	cmd.Flags().BoolVar(&opt.PubliclyAccessible, "publicly_accessible", false, "Make RDS instance publicly accessible")
 	cmd.Flags().BoolVar(&opt.MultiAZ, "multi_az", false, "Enable Multi-AZ deployment")
 
+	cmd.Flags().IntVar(&opt.BackupRetentionPeriod, "backup_retention_period", 1, "Automated backup retention (days, 0 disables backups)")
+	cmd.Flags().StringVar(&opt.PreferredBackupWindow, "preferred_backup_window", "", "Daily backup window (UTC), e.g. 18:00-19:00")
+	cmd.Flags().StringVar(&opt.PreferredMaintenanceWindow, "preferred_maintenance_window", "", "Weekly maintenance window (UTC), e.g. sun:19:00-sun:20:00")
+	cmd.Flags().BoolVar(&opt.CopyTagsToSnapshot, "copy_tags_to_snapshot", false, "Copy instance tags to snapshots")
+	cmd.Flags().BoolVar(&opt.AutoMinorVersionUpgrade, "auto_minor_version_upgrade", true, "Apply minor engine upgrades automatically")
+	cmd.Flags().BoolVar(&opt.DeletionProtection, "deletion_protection", false, "Protect the RDS instance against deletion")
+
+	cmd.Flags().BoolVar(&opt.PerformanceInsights, "performance_insights", false, "Enable Performance Insights")
+	cmd.Flags().IntVar(&opt.PerformanceInsightsRetention, "performance_insights_retention", 7, "Performance Insights retention (days)")
+	cmd.Flags().StringVar(&opt.PerformanceInsightsKMSKeyID, "performance_insights_kms_key_id", "", "KMS key for Performance Insights data")
+	cmd.Flags().IntVar(&opt.MonitoringInterval, "monitoring_interval", 0, "Enhanced Monitoring interval in seconds (0 disables)")
+	cmd.Flags().StringVar(&opt.MonitoringRoleARN, "monitoring_role_arn", "", "Enhanced Monitoring role ARN (created if empty)")
+	cmd.Flags().StringVar(&logExports, "log_exports", "", "Comma-separated CloudWatch log types to export, or \"all\"")
+
+	cmd.Flags().StringVar(&opt.CACertificateIdentifier, "ca_certificate_identifier", "", "RDS CA for the instance certificate, e.g. rds-ca-rsa2048-g1")
+	cmd.Flags().BoolVar(&opt.RequireTLS, "require_tls", false, "Verify the instance certificate and export a TLS DSN")
+	cmd.Flags().StringVar(&opt.CABundlePath, "ca_bundle_path", "", "Write the RDS CA bundle here (default: export it as DB_SSL_ROOT_CERT content)")
+
+	cmd.Flags().StringVar(&events, "events", "", "Comma-separated RDS event categories to publish to SNS, or \"all\"")
+	cmd.Flags().StringVar(&opt.EventsSNSTopicARN, "events_sns_topic_arn", "", "SNS topic for RDS events (created if empty)")
+
+	cmd.Flags().StringVar(&opt.SharedInstance, "shared_instance", "", "Identifier of an existing RDS instance to host this service's database")
+	cmd.Flags().StringVar(&opt.AppUsername, "app_username", "", "App role created on the shared instance (default: <name>_app)")
+	cmd.Flags().StringVar(&opt.AppPassword, "app_password", "", "App role password (generated on every up if empty)")
+
+	cmd.Flags().StringVar(&opt.TunnelHost, "tunnel_host", "", "SSH bastion host for reaching a private RDS instance")
+	cmd.Flags().IntVar(&opt.TunnelPort, "tunnel_port", 22, "SSH bastion port")
+	cmd.Flags().StringVar(&opt.TunnelUser, "tunnel_user", "ec2-user", "SSH user on the bastion")
+	cmd.Flags().StringVar(&opt.TunnelKey, "tunnel_key", "", "Path to the SSH private key")
+	cmd.Flags().StringVar(&opt.TunnelKnownHosts, "tunnel_known_hosts", "", "known_hosts file (default: ~/.ssh/known_hosts)")
+	cmd.Flags().BoolVar(&opt.TunnelInsecureHostKey, "tunnel_insecure_host_key", false, "Skip bastion host key verification")
+	cmd.Flags().IntVar(&opt.TunnelLocalPort, "tunnel_local_port", 0, "Local tunnel port (default: the instance port)")
+	cmd.Flags().StringVar(&opt.TunnelBindAddress, "tunnel_bind_address", "", "Address the local tunnel listener binds to (default: the docker0 bridge on Linux, else 127.0.0.1)")
+	cmd.Flags().StringVar(&opt.TunnelExportHost, "tunnel_export_host", "host.docker.internal", "DB_HOST exported when tunnelling")
+
+	cmd.Flags().StringVar(&opt.ReadinessCheck, "readiness_check", "tcp", "Readiness probe before exporting env: none, tcp or ping")
+	cmd.Flags().IntVar(&opt.ReadinessTimeout, "readiness_timeout", 300, "Readiness probe timeout (seconds)")
+	cmd.Flags().IntVar(&opt.ReadinessInterval, "readiness_interval", 5, "Delay between readiness attempts (seconds)")
+
+	cmd.Flags().StringVar(&opt.InitSQLDir, "init_sql_dir", "", "Directory of *.sql files applied once, in lexical order")
+	cmd.Flags().StringVar(&seedFiles, "seed_files", "", "Comma-separated SQL seed files applied once, after init_sql_dir")
+
+	cmd.Flags().BoolVar(&opt.Reconcile, "reconcile", false, "Apply drifted settings to an existing RDS instance")
+	cmd.Flags().BoolVar(&opt.ApplyImmediately, "apply_immediately", false, "Apply reconciled changes immediately instead of in the maintenance window")
+
+	cmd.Flags().StringVar(&opt.DownAction, "down_action", "delete", "RDS down behaviour (used by down only)")
+
+	cmd.Flags().BoolVar(&opt.Force, "force", false, "Disable RDS deletion protection before deleting (used by down only)")
+
+	cmd.Flags().StringVar(&opt.DRRegion, "dr_region", "", "Region for the cross-region DR copy (rds and s3)")
+	cmd.Flags().StringVar(&opt.DRMode, "dr_mode", "replica", "RDS DR mode: replica (read replica) or backups (automated backup replication)")
+
 	// S3-related options
 	cmd.Flags().StringVar(&opt.BucketName, "bucket_name", "", "S3 bucket name (optional; will be derived if empty)")
+	cmd.Flags().BoolVar(&opt.AdoptBucket, "adopt_bucket", false, "Tag and reuse an existing bucket that has no ownership tags")
+	cmd.Flags().BoolVar(&opt.EmptyOnDown, "empty_on_down", false, "Delete all objects and versions before deleting the bucket (used by down only)")
+	cmd.Flags().StringVar(&opt.BackupOnDown, "backup_on_down", "", "Local directory or .tar.gz to back up the bucket to before deleting it (used by down only)")
+	cmd.Flags().StringVar(&opt.RestoreFrom, "restore_from", "", "Backup directory or .tar.gz to restore into a newly created bucket")
+	cmd.Flags().StringVar(&opt.SeedDir, "seed_dir", "", "Local directory synced into the bucket")
+	cmd.Flags().StringVar(&opt.SeedPrefix, "seed_prefix", "", "Key prefix for files uploaded from seed_dir")
+
+	cmd.Flags().StringVar(&opt.Encryption, "encryption", "", "S3 default encryption: sse-s3 or sse-kms (default: leave unchanged)")
+	cmd.Flags().StringVar(&opt.KMSKeyID, "kms_key_id", "", "KMS key for sse-kms (default: the aws/s3 managed key)")
+	cmd.Flags().StringVar(&opt.DRKMSKeyID, "dr_kms_key_id", "", "KMS key in dr_region for sse-kms replicas (default: the aws/s3 managed key)")
+	cmd.Flags().BoolVar(&opt.BucketKey, "bucket_key", false, "Enable S3 Bucket Keys to reduce KMS requests")
+	cmd.Flags().BoolVar(&opt.EnforceTLS, "enforce_tls", false, "Deny requests to the bucket that do not use TLS")
+
+	cmd.Flags().BoolVar(&opt.PublicRead, "public_read", false, "Allow anyone to read objects (below public_read_prefix)")
+	cmd.Flags().StringVar(&opt.PublicReadPrefix, "public_read_prefix", "", "Key prefix made public by public_read (default: the whole bucket)")
+	cmd.Flags().BoolVar(&opt.ManagePublicAccess, "manage_public_access", false, "Disable ACLs and set the public access block on an adopted bucket too")
+
+	cmd.Flags().StringVar(&opt.FrontendOrigin, "frontend_origin", "", "Browser origin allowed by the default CORS rule, e.g. http://localhost:3000")
+	cmd.Flags().StringVar(&corsOrigins, "cors_allowed_origins", "", "Comma-separated CORS origins (default: frontend_origin)")
+	cmd.Flags().StringVar(&corsMethods, "cors_allowed_methods", "", "Comma-separated CORS methods (default: GET,HEAD,PUT,POST)")
+	cmd.Flags().StringVar(&corsHeaders, "cors_allowed_headers", "", "Comma-separated CORS request headers (default: *)")
+	cmd.Flags().StringVar(&corsExposeHeaders, "cors_expose_headers", "", "Comma-separated response headers exposed to the browser (default: ETag)")
+	cmd.Flags().IntVar(&opt.CORSMaxAge, "cors_max_age", 3000, "CORS preflight cache time (seconds)")
+
+	cmd.Flags().StringVar(&opt.Versioning, "versioning", "", "S3 versioning: enabled or suspended (default: leave unchanged)")
+	cmd.Flags().IntVar(&opt.LifecycleExpirationDays, "lifecycle_expiration_days", 0, "Expire current objects after N days")
+	cmd.Flags().IntVar(&opt.LifecycleNoncurrentExpirationDays, "lifecycle_noncurrent_expiration_days", 0, "Expire noncurrent versions after N days")
+	cmd.Flags().StringVar(&lifecycleTransitions, "lifecycle_transitions", "", "Comma-separated <days>:<storage class> transitions, e.g. 30:STANDARD_IA,90:GLACIER")
+	cmd.Flags().IntVar(&opt.LifecycleAbortMultipartDays, "lifecycle_abort_multipart_days", 0, "Abort incomplete multipart uploads after N days")
+	cmd.Flags().StringVar(&opt.LifecyclePrefix, "lifecycle_prefix", "", "Only apply the lifecycle rule to keys with this prefix")
+	cmd.Flags().StringVar(&lifecycleTags, "lifecycle_tags", "", "Comma-separated key=value object tags the lifecycle rule is limited to")
 
 	return cmd
 }
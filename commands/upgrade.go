@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"context"
+
+	"github.com/InspectorGadget/aws-compose-service/controllers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/spf13/cobra"
+)
+
+// NewUpgradeCommand wires "aws-compose-service upgrade".
+func NewUpgradeCommand(ctx context.Context, opt *structs.Options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade an RDS instance through a Blue/Green deployment",
+		Long:  `upgrade creates an RDS Blue/Green deployment towards a target engine version and/or parameter group, waits for the green environment, checks that it accepts connections, switches over and exports the (unchanged) endpoints.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return controllers.ParseUpgradeCommand(ctx, *opt)
+		},
+	}
+
+	// Service selection (also available globally)
+	cmd.Flags().StringVar(&opt.Service, "service", opt.Service, "AWS service to manage (rds)")
+
+	cmd.Flags().StringVar(&opt.Region, "region", "ap-southeast-1", "AWS region")
+	cmd.Flags().StringVar(&opt.EngineVersion, "engine_version", "", "Target engine version")
+	cmd.Flags().StringVar(&opt.DBParameterGroup, "db_parameter_group", "", "Target DB parameter group")
+	cmd.Flags().IntVar(&opt.SwitchoverTimeout, "switchover_timeout", 300, "Switchover timeout (seconds)")
+
+	// Used to check the green environment and to export env afterwards
+	cmd.Flags().StringVar(&opt.OracleConnectBy, "oracle_connect_by", "service", "Oracle DSN format: service (service name) or sid")
+	cmd.Flags().StringVar(&opt.DBName, "db_name", "", "Database name (default: app)")
+	cmd.Flags().StringVar(&opt.Username, "username", "admin", "Master username")
+	cmd.Flags().StringVar(&opt.Password, "password", "password", "Master password")
+
+	cmd.Flags().StringVar(&opt.ReadinessCheck, "readiness_check", "tcp", "Readiness probe against the green instance: none, tcp or ping")
+	cmd.Flags().IntVar(&opt.ReadinessTimeout, "readiness_timeout", 300, "Readiness probe timeout (seconds)")
+	cmd.Flags().IntVar(&opt.ReadinessInterval, "readiness_interval", 5, "Delay between readiness attempts (seconds)")
+
+	cmd.Flags().BoolVar(&opt.RequireTLS, "require_tls", false, "Verify the instance certificate and export a TLS DSN")
+	cmd.Flags().StringVar(&opt.CABundlePath, "ca_bundle_path", "", "Write the RDS CA bundle here (default: export it as DB_SSL_ROOT_CERT content)")
+
+	// SSH tunnel to a private instance, for the green check and the exported endpoint
+	cmd.Flags().StringVar(&opt.TunnelHost, "tunnel_host", "", "SSH bastion host for reaching a private RDS instance")
+	cmd.Flags().IntVar(&opt.TunnelPort, "tunnel_port", 22, "SSH bastion port")
+	cmd.Flags().StringVar(&opt.TunnelUser, "tunnel_user", "ec2-user", "SSH user on the bastion")
+	cmd.Flags().StringVar(&opt.TunnelKey, "tunnel_key", "", "Path to the SSH private key")
+	cmd.Flags().StringVar(&opt.TunnelKnownHosts, "tunnel_known_hosts", "", "known_hosts file (default: ~/.ssh/known_hosts)")
+	cmd.Flags().BoolVar(&opt.TunnelInsecureHostKey, "tunnel_insecure_host_key", false, "Skip bastion host key verification")
+	cmd.Flags().IntVar(&opt.TunnelLocalPort, "tunnel_local_port", 0, "Local tunnel port (default: the instance port)")
+	cmd.Flags().StringVar(&opt.TunnelBindAddress, "tunnel_bind_address", "", "Address the local tunnel listener binds to (default: the docker0 bridge on Linux, else 127.0.0.1)")
+	cmd.Flags().StringVar(&opt.TunnelExportHost, "tunnel_export_host", "host.docker.internal", "DB_HOST exported when tunnelling")
+
+	return cmd
+}
@@ -0,0 +1,41 @@
+package commands
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+
+	"github.com/InspectorGadget/aws-compose-service/controllers"
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/spf13/cobra"
+)
+
+// NewWatchCommand wires "aws-compose-service watch".
+func NewWatchCommand(ctx context.Context, opt *structs.Options) *cobra.Command {
+	var events string
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Stream RDS events of a service as JSONL",
+		Long:  `watch polls DescribeEvents for the service's RDS instance and prints failovers, reboots, storage-full, maintenance and other events as JSONL until interrupted.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opt.Events = helpers.SplitAndTrim(events)
+
+			ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+			defer stop()
+
+			return controllers.ParseWatchCommand(ctx, *opt)
+		},
+	}
+
+	// Service selection (also available globally)
+	cmd.Flags().StringVar(&opt.Service, "service", opt.Service, "AWS service to manage (rds)")
+
+	cmd.Flags().StringVar(&opt.Region, "region", "ap-southeast-1", "AWS region")
+	cmd.Flags().StringVar(&events, "events", "", "Comma-separated event categories, or \"all\" (default: availability, failover, failure, low storage, maintenance)")
+	cmd.Flags().IntVar(&opt.WatchInterval, "watch_interval", 30, "Polling interval (seconds)")
+	cmd.Flags().IntVar(&opt.WatchSince, "watch_since", 60, "Also print events from the last N minutes")
+
+	return cmd
+}
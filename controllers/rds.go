@@ -2,7 +2,12 @@ package controllers
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,19 +18,6 @@ import (
 	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
 )
 
-func defaultPortForEngine(engine string) int {
-	switch strings.ToLower(engine) {
-	case "postgres", "postgresql":
-		return 5432
-	case "mysql", "mariadb":
-		return 3306
-	case "sqlserver":
-		return 1433
-	default:
-		return 5432
-	}
-}
-
 func instanceClassOrDefault(v string) string {
 	if v == "" {
 		return "db.t3.micro"
@@ -40,14 +32,87 @@ func allocatedStorageOrDefault(v int) int32 {
 	return int32(v)
 }
 
+func backupRetentionOrDefault(v int) int32 {
+	if v < 0 {
+		return 1
+	}
+	return int32(v)
+}
+
+// rdsEnv is what gets exported to the Compose service for an RDS database.
+type rdsEnv struct {
+	Engine             string
+	Host               string
+	Port               int
+	DBName             string
+	Username           string
+	Password           string
+	Region             string
+	Endpoint           string // the instance endpoint, even when Host is a tunnel
+	InstanceIdentifier string
+
+	// TLS adds certificate verification to the DSN; RootCertPath is the
+	// written CA bundle, if any.
+	TLS          bool
+	RootCertPath string
+
+	// OracleConnectBy selects an Oracle DSN by service name (default) or SID.
+	OracleConnectBy string
+}
+
+// exportRDSEnv emits the connection details as setenv messages.
+func exportRDSEnv(env rdsEnv) {
+	spec := engineSpecFor(env.Engine)
+
+	// Verify the full hostname unless Host is a tunnel, which the
+	// certificate does not cover.
+	var tlsQuery url.Values
+	if env.TLS {
+		serverName, _, _ := net.SplitHostPort(env.Endpoint)
+		tunnelled := serverName != env.Host
+		tlsQuery = dsnTLSQuery(env.Engine, serverName, env.RootCertPath, tunnelled)
+		if tlsQuery == nil {
+			helpers.Info("require_tls: %s needs TLS configured through an option group; DB_DSN does not enforce it", env.Engine)
+		} else {
+			helpers.Setenv("DB_SSLMODE", rdsSSLMode(env.Engine, tunnelled))
+		}
+	}
+
+	dsn := spec.dsn(env, env.OracleConnectBy, tlsQuery)
+
+	helpers.Setenv("DB_ENGINE", env.Engine)
+	helpers.Setenv("DB_HOST", env.Host)
+	helpers.Setenv("DB_PORT", fmt.Sprintf("%d", env.Port))
+	helpers.Setenv("DB_NAME", env.DBName)
+	helpers.Setenv("DB_USER", env.Username)
+	helpers.Setenv("DB_PASSWORD", env.Password)
+	helpers.Setenv("DB_DSN", dsn)
+
+	if spec.Family == "oracle" {
+		helpers.Setenv("DB_ORACLE_SID", spec.oracleSID(env.DBName))
+		helpers.Setenv("DB_SERVICE_NAME", env.DBName)
+	}
+
+	helpers.Setenv("RDS_REGION", env.Region)
+	helpers.Setenv("RDS_ENDPOINT", env.Endpoint)
+	helpers.Setenv("RDS_INSTANCE_IDENTIFIER", env.InstanceIdentifier)
+}
+
 // RDSUp creates (or reuses) an RDS instance and exports its connection details
 // as environment variables for the Compose service.
 func RDSUp(ctx context.Context, opt structs.Options) error {
 	region := helpers.WithFallbackValue(opt.Region, "ap-southeast-1")
-	engine := helpers.WithFallbackValue(opt.Engine, "postgres")
-	dbName := helpers.WithFallbackValue(opt.DBName, "app")
 	name := helpers.WithFallbackValue(opt.Name, "rds")
 
+	spec, err := lookupEngine(helpers.WithFallbackValue(opt.Engine, "postgres"))
+	if err != nil {
+		helpers.Error("invalid engine: %v", err)
+		return err
+	}
+	engine := spec.Name
+	opt.Engine = engine
+	dbName := helpers.WithFallbackValue(opt.DBName, spec.DefaultDBName)
+
 	username := helpers.WithFallbackValue(opt.Username, "admin")
 	password := helpers.WithFallbackValue(opt.Password, "password")
 
@@ -59,25 +124,81 @@ func RDSUp(ctx context.Context, opt structs.Options) error {
 
 	client := rds.NewFromConfig(cfg)
 
+	// With shared_instance the service gets a logical database on an instance
+	// owned by another service instead of an instance of its own.
+	shared := opt.SharedInstance != ""
+	instanceName := name
+	if shared {
+		instanceName = opt.SharedInstance
+	}
+
 	// 1) Check if instance already exists
-	describeOut, err := client.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{
-		DBInstanceIdentifier: aws.String(name),
-	})
+	instance, err := findRDSInstance(ctx, client, instanceName)
 	if err != nil {
-		switch err.(type) {
-		case *rdstypes.DBInstanceNotFoundFault:
-			// not found, proceed to create
-		default:
-			helpers.Error("describe DB instances failed: %v", err)
+		helpers.Error("describe DB instances failed: %v", err)
+		return err
+	}
+
+	if instance == nil && shared {
+		helpers.Error("shared RDS instance %s does not exist in %s; bring up the service that owns it first", instanceName, region)
+		return fmt.Errorf("shared db instance %s not found", instanceName)
+	}
+
+	// The shared instance's observability settings belong to its owner
+	if !shared {
+		observedEngine := engine
+		if instance != nil {
+			observedEngine = aws.ToString(instance.Engine)
+		}
+		if err := prepareRDSObservability(observedEngine, &opt); err != nil {
+			helpers.Error("invalid observability options: %v", err)
+			return err
 		}
 	}
 
-	var instance *rdstypes.DBInstance
+	if instance != nil {
+		helpers.Info("reusing existing RDS instance %s in %s (status=%s)", instanceName, region, aws.ToString(instance.DBInstanceStatus))
+
+		// A full instance can only be recovered by growing its storage, which
+		// reconcile does below
+		if !shared && growsFullStorage(instance, opt) {
+			helpers.Info("RDS instance %s is storage-full; growing allocated_storage to %d GiB", instanceName, allocatedStorageOrDefault(opt.AllocatedStorage))
+		} else {
+			instance, err = ensureRDSInstanceReady(ctx, client, instance)
+			if err != nil {
+				return err
+			}
+		}
 
-	if len(describeOut.DBInstances) > 0 {
-		instance = &describeOut.DBInstances[0]
-		helpers.Info("reusing existing RDS instance %s in %s", name, region)
+		// The shared instance's settings belong to the service that owns it.
+		if !shared {
+			instance, err = reconcileRDSInstance(ctx, cfg, client, instance, opt, region)
+			if err != nil {
+				return err
+			}
+		}
 	} else {
+		err = checkOrderable(ctx, client, opt, region, engine, opt.EngineVersion, instanceClassOrDefault(opt.InstanceClass), opt.MultiAZ)
+		if err != nil {
+			return err
+		}
+
+		if err := spec.validateDBName(dbName); err != nil {
+			helpers.Error("%v", err)
+			return err
+		}
+		licenseModel, err := spec.licenseModel(opt.LicenseModel)
+		if err != nil {
+			helpers.Error("%v", err)
+			return err
+		}
+		if spec.Family == "db2" && licenseModel == "bring-your-own-license" {
+			if err := checkDb2License(ctx, client, opt.DBParameterGroup); err != nil {
+				helpers.Error("%v", err)
+				return err
+			}
+		}
+
 		helpers.Info("creating RDS instance %s in %s (engine=%s)", name, region, engine)
 
 		createInput := &rds.CreateDBInstanceInput{
@@ -87,10 +208,46 @@ func RDSUp(ctx context.Context, opt structs.Options) error {
 			MasterUserPassword:   aws.String(password),
 			DBInstanceClass:      aws.String(instanceClassOrDefault(opt.InstanceClass)),
 			AllocatedStorage:     aws.Int32(allocatedStorageOrDefault(opt.AllocatedStorage)),
-			DBName:               aws.String(dbName),
+			LicenseModel:         aws.String(licenseModel),
 
 			MultiAZ:            aws.Bool(opt.MultiAZ),
 			PubliclyAccessible: aws.Bool(opt.PubliclyAccessible),
+
+			BackupRetentionPeriod:   aws.Int32(backupRetentionOrDefault(opt.BackupRetentionPeriod)),
+			CopyTagsToSnapshot:      aws.Bool(opt.CopyTagsToSnapshot),
+			AutoMinorVersionUpgrade: aws.Bool(opt.AutoMinorVersionUpgrade),
+			DeletionProtection:      aws.Bool(opt.DeletionProtection),
+
+			Tags: rdsOwnershipTags(opt),
+		}
+
+		// SQL Server rejects DBName; its database is created once it is reachable
+		if spec.CreatesDatabase {
+			createInput.DBName = aws.String(dbName)
+		}
+
+		if opt.MonitoringInterval > 0 {
+			opt.MonitoringRoleARN, err = monitoringRoleARN(ctx, cfg, opt)
+			if err != nil {
+				helpers.Error("preparing Enhanced Monitoring role failed: %v", err)
+				return err
+			}
+		}
+		applyObservabilityToCreate(createInput, opt)
+
+		if opt.CACertificateIdentifier != "" {
+			createInput.CACertificateIdentifier = aws.String(opt.CACertificateIdentifier)
+		}
+		if opt.DBParameterGroup != "" {
+			createInput.DBParameterGroupName = aws.String(opt.DBParameterGroup)
+		}
+
+		// Optional backup / maintenance windows
+		if opt.PreferredBackupWindow != "" {
+			createInput.PreferredBackupWindow = aws.String(opt.PreferredBackupWindow)
+		}
+		if opt.PreferredMaintenanceWindow != "" {
+			createInput.PreferredMaintenanceWindow = aws.String(opt.PreferredMaintenanceWindow)
 		}
 
 		// Optional networking
@@ -114,55 +271,171 @@ func RDSUp(ctx context.Context, opt structs.Options) error {
 			return waitErr
 		}
 
-		describeOut, err = client.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{
-			DBInstanceIdentifier: aws.String(name),
-		})
-		if err != nil || len(describeOut.DBInstances) == 0 {
-			if err != nil {
-				helpers.Error("describe DB instance after creation failed: %v", err)
-			} else {
-				helpers.Error("describe DB instance after creation returned no instances")
-			}
-			return fmt.Errorf("could not find DB instance after creation")
+		instance, err = describeRDSInstance(ctx, client, name)
+		if err != nil {
+			helpers.Error("describe DB instance after creation failed: %v", err)
+			return fmt.Errorf("could not find DB instance after creation: %w", err)
+		}
+	}
+
+	// Publish the instance's events to SNS
+	if len(opt.Events) > 0 && !shared {
+		if err := ensureRDSEventSubscription(ctx, cfg, client, opt, name); err != nil {
+			return err
+		}
+	}
+
+	// Cross-region DR copy of the service's own instance
+	if opt.DRRegion != "" && !shared {
+		if err := ensureRDSDisasterRecovery(ctx, instance, opt, region); err != nil {
+			return err
 		}
-		instance = &describeOut.DBInstances[0]
 	}
 
 	// 2) Get endpoint & port
 	if instance.Endpoint == nil || instance.Endpoint.Address == nil {
-		helpers.Error("DB instance %s does not have an endpoint yet", name)
+		helpers.Error("DB instance %s does not have an endpoint yet", instanceName)
 		return fmt.Errorf("db instance has no endpoint")
 	}
 
+	// A shared instance dictates the engine regardless of the options.
+	if shared {
+		engine = aws.ToString(instance.Engine)
+		spec = engineSpecFor(engine)
+	}
+
 	host := aws.ToString(instance.Endpoint.Address)
 	port := int(aws.ToInt32(instance.Endpoint.Port))
 	if port == 0 {
-		port = defaultPortForEngine(engine)
+		port = engineSpecFor(engine).Port
 	}
 
-	// DSN
-	dsn := fmt.Sprintf(
-		"%s://%s:%s@%s:%d/%s",
-		engine,
-		username,
-		password,
-		host,
-		port,
-		dbName,
-	)
+	// connectHost/connectPort is how this process reaches the database and
+	// exportHost/exportPort is what the containers get; they only differ when
+	// a private instance is reached through an SSH tunnel.
+	route, err := routeRDS(opt, host, port)
+	if err != nil {
+		helpers.Error("starting SSH tunnel failed: %v", err)
+		return err
+	}
+	connectHost, connectPort := route.ConnectHost, route.ConnectPort
+	exportHost, exportPort := route.ExportHost, route.ExportPort
 
-	// 3) Export env vars
-	helpers.Setenv("DB_ENGINE", engine)
-	helpers.Setenv("DB_HOST", host)
-	helpers.Setenv("DB_PORT", fmt.Sprintf("%d", port))
-	helpers.Setenv("DB_NAME", dbName)
-	helpers.Setenv("DB_USER", username)
-	helpers.Setenv("DB_PASSWORD", password)
-	helpers.Setenv("DB_DSN", dsn)
+	// CA bundle for the app, and TLS for our own connections with require_tls
+	tlsSetup, err := prepareRDSTLS(ctx, opt, region, host)
+	if err != nil {
+		helpers.Error("preparing RDS TLS failed: %v", err)
+		return err
+	}
+	var tlsConfig *tls.Config
+	if tlsSetup != nil {
+		tlsConfig = tlsSetup.Config
+	}
+
+	// Make sure dependents can actually connect before anything is exported
+	readiness := readinessSettings{
+		Mode:     opt.ReadinessCheck,
+		Timeout:  time.Duration(opt.ReadinessTimeout) * time.Second,
+		Interval: time.Duration(opt.ReadinessInterval) * time.Second,
+	}
+	probe := rdsConnection{
+		Engine:   engine,
+		Host:     connectHost,
+		Port:     connectPort,
+		Username: username,
+		Password: password,
+		DBName:   dbName,
+		TLS:      tlsConfig,
+	}
+	if shared || !spec.CreatesDatabase {
+		probe = probe.withDatabase(adminDatabase(sqlFamily(engine)))
+	}
+	if err := probeRDSReadiness(ctx, probe, readiness); err != nil {
+		return err
+	}
+
+	if !shared && !spec.CreatesDatabase {
+		master := rdsConnection{
+			Engine:   engine,
+			Host:     connectHost,
+			Port:     connectPort,
+			Username: username,
+			Password: password,
+			TLS:      tlsConfig,
+		}
+		if err := ensureSQLServerDatabase(ctx, master, dbName); err != nil {
+			helpers.Error("creating database %s failed: %v", dbName, err)
+			return err
+		}
+	}
+
+	// On a shared instance, create the service's database and role as master
+	// and hand out the role's credentials instead of the master's.
+	if shared {
+		appDBName, appUser, err := sharedDatabaseNames(opt)
+		if err != nil {
+			helpers.Error("invalid shared database options: %v", err)
+			return err
+		}
+
+		appPassword := opt.AppPassword
+		if appPassword == "" {
+			appPassword, err = generatePassword(24)
+			if err != nil {
+				helpers.Error("generating app password failed: %v", err)
+				return err
+			}
+		}
+
+		helpers.Info("provisioning database %s and role %s on shared RDS instance %s", appDBName, appUser, instanceName)
 
-	helpers.Setenv("RDS_REGION", region)
-	helpers.Setenv("RDS_ENDPOINT", fmt.Sprintf("%s:%d", host, port))
-	helpers.Setenv("RDS_INSTANCE_IDENTIFIER", name)
+		master := rdsConnection{
+			Engine:   engine,
+			Host:     connectHost,
+			Port:     connectPort,
+			Username: username,
+			Password: password,
+			TLS:      tlsConfig,
+		}
+		if err := provisionSharedDatabase(ctx, master, appDBName, appUser, appPassword); err != nil {
+			helpers.Error("provisioning shared database failed: %v", err)
+			return err
+		}
+
+		dbName, username, password = appDBName, appUser, appPassword
+	}
+
+	// 3) Apply SQL migrations and seed data
+	conn := rdsConnection{
+		Engine:   engine,
+		Host:     connectHost,
+		Port:     connectPort,
+		Username: username,
+		Password: password,
+		DBName:   dbName,
+		TLS:      tlsConfig,
+	}
+	if err := applyRDSMigrations(ctx, conn, opt.InitSQLDir, opt.SeedFiles); err != nil {
+		helpers.Error("applying SQL files failed: %v", err)
+		return err
+	}
+
+	// 4) Export env vars
+	exportRDSEnv(rdsEnv{
+		Engine:             engine,
+		Host:               exportHost,
+		Port:               exportPort,
+		DBName:             dbName,
+		Username:           username,
+		Password:           password,
+		Region:             region,
+		Endpoint:           net.JoinHostPort(host, strconv.Itoa(port)),
+		InstanceIdentifier: instanceName,
+		TLS:                opt.RequireTLS,
+		RootCertPath:       rootCertPath(tlsSetup),
+		OracleConnectBy:    opt.OracleConnectBy,
+	})
+	exportRDSTLSEnv(tlsSetup)
 
 	helpers.Info(
 		"aws-compose-service (service=rds) ready for %s (engine=%s endpoint=%s:%d)",
@@ -175,10 +448,13 @@ func RDSUp(ctx context.Context, opt structs.Options) error {
 	return nil
 }
 
-// RDSDown deletes the RDS instance (without final snapshot) for the given service.
+// RDSDown brings the RDS instance for the given service offline according to
+// down_action: stop it, delete it (without final snapshot) or delete it after
+// taking a final snapshot.
 func RDSDown(ctx context.Context, opt structs.Options) error {
 	region := helpers.WithFallbackValue(opt.Region, "ap-southeast-1")
 	name := helpers.WithFallbackValue(opt.Name, "rds")
+	action := strings.ToLower(helpers.WithFallbackValue(opt.DownAction, "delete"))
 
 	cfg, err := helpers.LoadAWSConfig(ctx, region)
 	if err != nil {
@@ -188,19 +464,255 @@ func RDSDown(ctx context.Context, opt structs.Options) error {
 
 	client := rds.NewFromConfig(cfg)
 
-	helpers.Info("deleting RDS instance %s in region %s (skip final snapshot)", name, region)
+	// The SSH tunnel helper, if any, is only needed until the database is gone
+	defer func() {
+		if err := stopTunnelHelper(opt); err != nil {
+			helpers.Error("stopping SSH tunnel failed: %v", err)
+		}
+	}()
+
+	if opt.SharedInstance != "" {
+		drop, err := sharedDownDrops(action)
+		if err != nil {
+			helpers.Error("shared RDS instance %s: %v", opt.SharedInstance, err)
+			return err
+		}
+		if !drop {
+			helpers.Info("down_action %s leaves database and role of %s on shared RDS instance %s in place", action, name, opt.SharedInstance)
+			return nil
+		}
+		return sharedRDSDown(ctx, client, opt)
+	}
+
+	switch action {
+	case "stop":
+		// The DR copy is kept on stop; RDS cannot stop an instance that still
+		// has a read replica, so refuse rather than delete the replica
+		if opt.DRRegion != "" {
+			mode, err := rdsDRMode(opt)
+			if err != nil {
+				helpers.Error("invalid DR options: %v", err)
+				return err
+			}
+			if mode == "replica" {
+				helpers.Error("RDS instance %s has a cross-region read replica in %s, which RDS does not allow to stop; the replica was left in place (use down_action delete or snapshot to remove both)", name, opt.DRRegion)
+				return fmt.Errorf("db instance %s has a read replica and cannot be stopped", name)
+			}
+		}
+		return stopRDSInstance(ctx, client, name, region)
+	case "delete", "snapshot":
+		// Everything that can refuse the delete runs before the DR copy goes
+		if err := releaseDeletionProtection(ctx, client, name, opt.Force); err != nil {
+			return err
+		}
+
+		// The DR copy depends on the primary, so it goes first
+		if opt.DRRegion != "" {
+			if err := teardownRDSDisasterRecovery(ctx, client, opt, name); err != nil {
+				return err
+			}
+		}
+		if err := deleteRDSEventSubscription(ctx, cfg, client, opt, name); err != nil {
+			return err
+		}
+		if err := deletePreRestoreInstances(ctx, client, opt, name, region); err != nil {
+			return err
+		}
+		return deleteRDSInstance(ctx, client, name, region, action == "snapshot", rdsOwnershipTags(opt))
+	default:
+		helpers.Error("unsupported down_action: %s (expected: stop, delete or snapshot)", action)
+		return fmt.Errorf("unsupported down_action %q", action)
+	}
+}
+
+// sharedRDSDown drops the service's logical database and role from the shared
+// instance. The instance itself belongs to another service and is left running.
+func sharedRDSDown(ctx context.Context, client *rds.Client, opt structs.Options) error {
+	dbName, appUser, err := sharedDatabaseNames(opt)
+	if err != nil {
+		helpers.Error("invalid shared database options: %v", err)
+		return err
+	}
+
+	instance, err := findRDSInstance(ctx, client, opt.SharedInstance)
+	if err != nil {
+		helpers.Error("describe DB instances failed: %v", err)
+		return err
+	}
+	if instance == nil || instance.Endpoint == nil {
+		helpers.Info("shared RDS instance %s is not reachable, nothing to drop", opt.SharedInstance)
+		return nil
+	}
+
+	engine := aws.ToString(instance.Engine)
+	master := rdsConnection{
+		Engine:   engine,
+		Host:     aws.ToString(instance.Endpoint.Address),
+		Port:     int(aws.ToInt32(instance.Endpoint.Port)),
+		Username: helpers.WithFallbackValue(opt.Username, "admin"),
+		Password: helpers.WithFallbackValue(opt.Password, "password"),
+	}
+	if master.Port == 0 {
+		master.Port = engineSpecFor(engine).Port
+	}
+
+	tlsSetup, err := prepareRDSTLS(ctx, opt, helpers.WithFallbackValue(opt.Region, "ap-southeast-1"), master.Host)
+	if err != nil {
+		helpers.Error("preparing RDS TLS failed: %v", err)
+		return err
+	}
+	if tlsSetup != nil {
+		master.TLS = tlsSetup.Config
+	}
+
+	// A private shared instance is only reachable through the tunnel
+	route, err := routeRDS(opt, master.Host, master.Port)
+	if err != nil {
+		helpers.Error("starting SSH tunnel failed: %v", err)
+		return err
+	}
+	master.Host, master.Port = route.ConnectHost, route.ConnectPort
+
+	helpers.Info("dropping database %s and role %s from shared RDS instance %s", dbName, appUser, opt.SharedInstance)
+
+	if err := dropSharedDatabase(ctx, master, dbName, appUser); err != nil {
+		helpers.Error("dropping shared database failed: %v", err)
+		return err
+	}
+
+	helpers.Info("database %s and role %s dropped from %s", dbName, appUser, opt.SharedInstance)
+	return nil
+}
+
+// stopRDSInstance stops the instance so it can be started again on the next up
+// with its data intact.
+func stopRDSInstance(ctx context.Context, client *rds.Client, name, region string) error {
+	helpers.Info("stopping RDS instance %s in region %s", name, region)
+
+	_, err := client.StopDBInstance(ctx, &rds.StopDBInstanceInput{
+		DBInstanceIdentifier: aws.String(name),
+	})
+	if err != nil {
+		var notFound *rdstypes.DBInstanceNotFoundFault
+		if errors.As(err, &notFound) {
+			helpers.Info("RDS instance %s does not exist, nothing to stop", name)
+			return nil
+		}
+
+		var invalidState *rdstypes.InvalidDBInstanceStateFault
+		if !errors.As(err, &invalidState) {
+			helpers.Error("stop DB instance failed: %v", err)
+			return err
+		}
+
+		if err := stopRDSInstanceFromState(ctx, client, name, err); err != nil {
+			return err
+		}
+	}
+
+	// Wait until the instance is stopped
+	if err := waitForRDSStatus(ctx, client, name, "stopped", 30*time.Minute); err != nil {
+		helpers.Error("waiting for DB instance to stop failed: %v", err)
+		return err
+	}
+
+	helpers.Info("RDS instance %s successfully stopped", name)
+	return nil
+}
+
+// stopRDSInstanceFromState handles a stop request the instance refused because
+// of its state: a stopped or stopping instance needs nothing more, a busy one
+// (modifying, backing-up, ...) is stopped once it is available again. Any
+// other state is an error, so down never reports a running instance as stopped.
+func stopRDSInstanceFromState(ctx context.Context, client *rds.Client, name string, stopErr error) error {
+	instance, err := describeRDSInstance(ctx, client, name)
+	if err != nil {
+		helpers.Error("describe DB instance failed: %v", err)
+		return err
+	}
+
+	switch status := aws.ToString(instance.DBInstanceStatus); status {
+	case "stopped", "stopping":
+		helpers.Info("RDS instance %s is already %s", name, status)
+		return nil
+	case "available":
+		helpers.Error("stop DB instance failed: %v", stopErr)
+		return stopErr
+	default:
+		helpers.Info("RDS instance %s is %s, waiting for it to become available before stopping it", name, status)
+	}
+
+	if err := waitForRDSStatus(ctx, client, name, "available", 30*time.Minute); err != nil {
+		helpers.Error("waiting for DB instance to become available failed: %v", err)
+		return err
+	}
+
+	_, err = client.StopDBInstance(ctx, &rds.StopDBInstanceInput{
+		DBInstanceIdentifier: aws.String(name),
+	})
+	if err != nil {
+		helpers.Error("stop DB instance failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// releaseDeletionProtection refuses to continue when the instance is protected
+// against deletion, unless force is set, in which case protection is disabled.
+func releaseDeletionProtection(ctx context.Context, client *rds.Client, name string, force bool) error {
+	instance, err := findRDSInstance(ctx, client, name)
+	if err != nil {
+		helpers.Error("describe DB instances failed: %v", err)
+		return err
+	}
+	if instance == nil || !aws.ToBool(instance.DeletionProtection) {
+		return nil
+	}
 
-	_, err = client.DeleteDBInstance(ctx, &rds.DeleteDBInstanceInput{
+	if !force {
+		helpers.Error("RDS instance %s has deletion protection enabled; re-run down with --force to disable it and delete the instance", name)
+		return fmt.Errorf("db instance %s has deletion protection enabled", name)
+	}
+
+	helpers.Info("disabling deletion protection on RDS instance %s (--force)", name)
+
+	_, err = client.ModifyDBInstance(ctx, &rds.ModifyDBInstanceInput{
 		DBInstanceIdentifier: aws.String(name),
-		SkipFinalSnapshot:    aws.Bool(true),
+		DeletionProtection:   aws.Bool(false),
+		ApplyImmediately:     aws.Bool(true),
 	})
 	if err != nil {
-		switch err.(type) {
-		case *rdstypes.DBInstanceNotFoundFault:
+		helpers.Error("disable deletion protection failed: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// deleteRDSInstance deletes the instance, optionally taking a final snapshot
+// first. The final snapshot gets snapshotTags so snapshot list / restore find
+// it even when the instance did not copy its tags to snapshots.
+func deleteRDSInstance(ctx context.Context, client *rds.Client, name, region string, finalSnapshot bool, snapshotTags []rdstypes.Tag) error {
+	var snapshotID string
+	deleteInput := &rds.DeleteDBInstanceInput{
+		DBInstanceIdentifier: aws.String(name),
+		SkipFinalSnapshot:    aws.Bool(!finalSnapshot),
+	}
+
+	if finalSnapshot {
+		snapshotID = fmt.Sprintf("%s-final-%s", name, time.Now().UTC().Format("20060102-150405"))
+		deleteInput.FinalDBSnapshotIdentifier = aws.String(snapshotID)
+		helpers.Info("deleting RDS instance %s in region %s (final snapshot %s)", name, region, snapshotID)
+	} else {
+		helpers.Info("deleting RDS instance %s in region %s (skip final snapshot)", name, region)
+	}
+
+	_, err := client.DeleteDBInstance(ctx, deleteInput)
+	if err != nil {
+		var notFound *rdstypes.DBInstanceNotFoundFault
+		if errors.As(err, &notFound) {
 			helpers.Info("RDS instance %s does not exist, nothing to delete", name)
 			return nil
-		default:
-			// real error, proceed
 		}
 
 		helpers.Error("delete DB instance failed: %v", err)
@@ -218,5 +730,95 @@ func RDSDown(ctx context.Context, opt structs.Options) error {
 	}
 
 	helpers.Info("RDS instance %s successfully deleted", name)
+
+	// The final snapshot exists once the instance is gone
+	if finalSnapshot && len(snapshotTags) > 0 {
+		out, err := client.DescribeDBSnapshots(ctx, &rds.DescribeDBSnapshotsInput{
+			DBSnapshotIdentifier: aws.String(snapshotID),
+		})
+		if err == nil && len(out.DBSnapshots) == 0 {
+			err = fmt.Errorf("snapshot %s not found", snapshotID)
+		}
+		if err == nil {
+			_, err = client.AddTagsToResource(ctx, &rds.AddTagsToResourceInput{
+				ResourceName: out.DBSnapshots[0].DBSnapshotArn,
+				Tags:         snapshotTags,
+			})
+		}
+		if err != nil {
+			helpers.Error("tagging final snapshot %s failed, snapshot restore will not find it: %v", snapshotID, err)
+			return err
+		}
+	}
+
 	return nil
 }
+
+// startRDSInstance starts a stopped instance and returns its description once
+// it is available again.
+func startRDSInstance(ctx context.Context, client *rds.Client, name string) (*rdstypes.DBInstance, error) {
+	helpers.Info("starting stopped RDS instance %s", name)
+
+	_, err := client.StartDBInstance(ctx, &rds.StartDBInstanceInput{
+		DBInstanceIdentifier: aws.String(name),
+	})
+	if err != nil {
+		helpers.Error("start DB instance failed: %v", err)
+		return nil, err
+	}
+
+	// Wait until the instance is available
+	if err := waitForRDSStatus(ctx, client, name, "available", 30*time.Minute); err != nil {
+		helpers.Error("waiting for DB instance to start failed: %v", err)
+		return nil, err
+	}
+
+	return describeRDSInstance(ctx, client, name)
+}
+
+// describeRDSInstance returns the description of a single instance.
+func describeRDSInstance(ctx context.Context, client *rds.Client, name string) (*rdstypes.DBInstance, error) {
+	describeOut, err := client.DescribeDBInstances(ctx, &rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(describeOut.DBInstances) == 0 {
+		return nil, fmt.Errorf("describe DB instance %s returned no instances", name)
+	}
+	return &describeOut.DBInstances[0], nil
+}
+
+// waitForRDSStatus polls the instance until it reports the wanted status. The
+// SDK only ships waiters for "available" and "deleted", and the available waiter
+// gives up as soon as it sees a stopped instance.
+func waitForRDSStatus(ctx context.Context, client *rds.Client, name, want string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		instance, err := describeRDSInstance(ctx, client, name)
+		if err != nil {
+			return err
+		}
+
+		status := aws.ToString(instance.DBInstanceStatus)
+		if status == want {
+			return nil
+		}
+		if hint, ok := rdsRefusedStates[status]; ok {
+			return fmt.Errorf("db instance %s is %s: %s", name, status, hint)
+		}
+		helpers.Debug("RDS instance %s is %s, waiting for %s", name, status, want)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for DB instance %s to become %s (last status: %s)", name, want, status)
+		case <-ticker.C:
+		}
+	}
+}
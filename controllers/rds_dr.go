@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// rdsDRMode returns the validated dr_mode: "replica" for a cross-region read
+// replica or "backups" for cross-region automated backup replication.
+func rdsDRMode(opt structs.Options) (string, error) {
+	mode := strings.ToLower(helpers.WithFallbackValue(opt.DRMode, "replica"))
+	switch mode {
+	case "replica", "backups":
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported dr_mode: %s (expected: replica or backups)", mode)
+	}
+}
+
+// drReplicaName is the identifier of the cross-region read replica.
+func drReplicaName(name string) string {
+	return name + "-dr"
+}
+
+// ensureRDSDisasterRecovery sets up the DR copy of the primary instance in
+// dr_region and exports where it lives.
+func ensureRDSDisasterRecovery(ctx context.Context, primary *rdstypes.DBInstance, opt structs.Options, region string) error {
+	mode, err := rdsDRMode(opt)
+	if err != nil {
+		helpers.Error("invalid DR options: %v", err)
+		return err
+	}
+
+	if opt.DRRegion == region {
+		helpers.Error("dr_region must differ from region (%s)", region)
+		return fmt.Errorf("dr_region equals region %s", region)
+	}
+
+	cfg, err := helpers.LoadAWSConfig(ctx, opt.DRRegion)
+	if err != nil {
+		helpers.Error("unable to load AWS config for %s: %v", opt.DRRegion, err)
+		return err
+	}
+
+	drClient := rds.NewFromConfig(cfg)
+	primaryARN := aws.ToString(primary.DBInstanceArn)
+
+	helpers.Setenv("DB_DR_REGION", opt.DRRegion)
+	helpers.Setenv("DB_DR_MODE", mode)
+
+	if mode == "backups" {
+		return ensureRDSBackupReplication(ctx, drClient, primary, opt.DRRegion)
+	}
+
+	replicaName := drReplicaName(aws.ToString(primary.DBInstanceIdentifier))
+
+	replica, err := findRDSInstance(ctx, drClient, replicaName)
+	if err != nil {
+		helpers.Error("describe DR replica failed: %v", err)
+		return err
+	}
+
+	if replica != nil {
+		helpers.Info("reusing DR read replica %s in %s (status=%s)", replicaName, opt.DRRegion, aws.ToString(replica.DBInstanceStatus))
+
+		replica, err = ensureRDSInstanceReady(ctx, drClient, replica)
+		if err != nil {
+			return err
+		}
+	} else {
+		helpers.Info("creating DR read replica %s of %s in %s", replicaName, aws.ToString(primary.DBInstanceIdentifier), opt.DRRegion)
+
+		_, err = drClient.CreateDBInstanceReadReplica(ctx, &rds.CreateDBInstanceReadReplicaInput{
+			DBInstanceIdentifier:       aws.String(replicaName),
+			SourceDBInstanceIdentifier: aws.String(primaryARN),
+			SourceRegion:               aws.String(region),
+			DBInstanceClass:            primary.DBInstanceClass,
+			PubliclyAccessible:         primary.PubliclyAccessible,
+			CopyTagsToSnapshot:         primary.CopyTagsToSnapshot,
+		})
+		if err != nil {
+			helpers.Error("create DR read replica failed: %v", err)
+			return err
+		}
+
+		// Cross-region replicas copy a full snapshot first, which takes a while
+		waiter := rds.NewDBInstanceAvailableWaiter(drClient)
+		waitErr := waiter.Wait(ctx, &rds.DescribeDBInstancesInput{
+			DBInstanceIdentifier: aws.String(replicaName),
+		}, 90*time.Minute)
+		if waitErr != nil {
+			helpers.Error("waiting for DR read replica to become available failed: %v", waitErr)
+			return waitErr
+		}
+
+		replica, err = describeRDSInstance(ctx, drClient, replicaName)
+		if err != nil {
+			helpers.Error("describe DR read replica after creation failed: %v", err)
+			return err
+		}
+	}
+
+	if replica.Endpoint == nil || replica.Endpoint.Address == nil {
+		helpers.Error("DR read replica %s does not have an endpoint yet", replicaName)
+		return fmt.Errorf("dr replica has no endpoint")
+	}
+
+	helpers.Setenv("DB_DR_HOST", aws.ToString(replica.Endpoint.Address))
+	helpers.Setenv("DB_DR_PORT", strconv.Itoa(int(aws.ToInt32(replica.Endpoint.Port))))
+	helpers.Setenv("DB_DR_INSTANCE_IDENTIFIER", replicaName)
+
+	return nil
+}
+
+// ensureRDSBackupReplication replicates the primary's automated backups to the
+// DR region. Point-in-time restores there need backups to be enabled.
+func ensureRDSBackupReplication(ctx context.Context, drClient *rds.Client, primary *rdstypes.DBInstance, drRegion string) error {
+	name := aws.ToString(primary.DBInstanceIdentifier)
+
+	if aws.ToInt32(primary.BackupRetentionPeriod) == 0 {
+		helpers.Error("dr_mode=backups needs automated backups; set backup_retention_period above 0")
+		return fmt.Errorf("automated backups are disabled on %s", name)
+	}
+
+	for _, r := range primary.DBInstanceAutomatedBackupsReplications {
+		if strings.Contains(aws.ToString(r.DBInstanceAutomatedBackupsArn), ":"+drRegion+":") {
+			helpers.Info("automated backups of %s are already replicated to %s", name, drRegion)
+			helpers.Setenv("DB_DR_BACKUP_ARN", aws.ToString(r.DBInstanceAutomatedBackupsArn))
+			return nil
+		}
+	}
+
+	helpers.Info("starting automated backup replication of %s to %s", name, drRegion)
+
+	out, err := drClient.StartDBInstanceAutomatedBackupsReplication(ctx, &rds.StartDBInstanceAutomatedBackupsReplicationInput{
+		SourceDBInstanceArn:   primary.DBInstanceArn,
+		BackupRetentionPeriod: primary.BackupRetentionPeriod,
+	})
+	if err != nil {
+		helpers.Error("start automated backup replication failed: %v", err)
+		return err
+	}
+
+	helpers.Setenv("DB_DR_BACKUP_ARN", aws.ToString(out.DBInstanceAutomatedBackup.DBInstanceAutomatedBackupsArn))
+	return nil
+}
+
+// teardownRDSDisasterRecovery removes the DR copy before the primary is deleted:
+// a source instance cannot be deleted while it still has a cross-region
+// replica, and replicated backups would otherwise outlive it. A stopped primary
+// keeps its DR copy, so this is not called on stop.
+func teardownRDSDisasterRecovery(ctx context.Context, client *rds.Client, opt structs.Options, name string) error {
+	mode, err := rdsDRMode(opt)
+	if err != nil {
+		helpers.Error("invalid DR options: %v", err)
+		return err
+	}
+
+	cfg, err := helpers.LoadAWSConfig(ctx, opt.DRRegion)
+	if err != nil {
+		helpers.Error("unable to load AWS config for %s: %v", opt.DRRegion, err)
+		return err
+	}
+
+	drClient := rds.NewFromConfig(cfg)
+
+	if mode == "replica" {
+		return deleteRDSInstance(ctx, drClient, drReplicaName(name), opt.DRRegion, false, nil)
+	}
+
+	primary, err := findRDSInstance(ctx, client, name)
+	if err != nil {
+		helpers.Error("describe DB instances failed: %v", err)
+		return err
+	}
+	if primary == nil {
+		return nil
+	}
+
+	helpers.Info("stopping automated backup replication of %s to %s", name, opt.DRRegion)
+
+	_, err = drClient.StopDBInstanceAutomatedBackupsReplication(ctx, &rds.StopDBInstanceAutomatedBackupsReplicationInput{
+		SourceDBInstanceArn: primary.DBInstanceArn,
+	})
+	if err != nil {
+		var notFound *rdstypes.DBInstanceAutomatedBackupNotFoundFault
+		var invalidState *rdstypes.InvalidDBInstanceAutomatedBackupStateFault
+		if errors.As(err, &notFound) || errors.As(err, &invalidState) {
+			helpers.Info("automated backups of %s are not replicated to %s, nothing to stop", name, opt.DRRegion)
+			return nil
+		}
+
+		helpers.Error("stop automated backup replication failed: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// rdsDrift describes a single setting whose live value differs from the
+// value requested through the Compose options.
+type rdsDrift struct {
+	Field   string
+	Current string
+	Desired string
+}
+
+// detectRDSDrift compares the desired options with the described instance and
+// returns every drift together with a ModifyDBInstance input that would fix it.
+// A nil input means there is nothing that can be reconciled.
+func detectRDSDrift(instance *rdstypes.DBInstance, opt structs.Options) ([]rdsDrift, *rds.ModifyDBInstanceInput) {
+	var drifts []rdsDrift
+	modify := &rds.ModifyDBInstanceInput{
+		DBInstanceIdentifier: instance.DBInstanceIdentifier,
+		ApplyImmediately:     aws.Bool(opt.ApplyImmediately),
+	}
+	changed := false
+
+	desiredClass := instanceClassOrDefault(opt.InstanceClass)
+	if currentClass := aws.ToString(instance.DBInstanceClass); currentClass != desiredClass {
+		drifts = append(drifts, rdsDrift{Field: "instance_class", Current: currentClass, Desired: desiredClass})
+		modify.DBInstanceClass = aws.String(desiredClass)
+		changed = true
+	}
+
+	desiredStorage := allocatedStorageOrDefault(opt.AllocatedStorage)
+	if currentStorage := aws.ToInt32(instance.AllocatedStorage); currentStorage != desiredStorage {
+		drifts = append(drifts, rdsDrift{
+			Field:   "allocated_storage",
+			Current: fmt.Sprintf("%d", currentStorage),
+			Desired: fmt.Sprintf("%d", desiredStorage),
+		})
+
+		// RDS can only grow storage; shrinking requires a new instance.
+		if desiredStorage > currentStorage {
+			modify.AllocatedStorage = aws.Int32(desiredStorage)
+			changed = true
+		} else {
+			helpers.Info("allocated_storage cannot be reduced from %d to %d GiB; leaving it unchanged", currentStorage, desiredStorage)
+		}
+	}
+
+	if currentMultiAZ := aws.ToBool(instance.MultiAZ); currentMultiAZ != opt.MultiAZ {
+		drifts = append(drifts, rdsDrift{
+			Field:   "multi_az",
+			Current: fmt.Sprintf("%t", currentMultiAZ),
+			Desired: fmt.Sprintf("%t", opt.MultiAZ),
+		})
+		modify.MultiAZ = aws.Bool(opt.MultiAZ)
+		changed = true
+	}
+
+	if currentPublic := aws.ToBool(instance.PubliclyAccessible); currentPublic != opt.PubliclyAccessible {
+		drifts = append(drifts, rdsDrift{
+			Field:   "publicly_accessible",
+			Current: fmt.Sprintf("%t", currentPublic),
+			Desired: fmt.Sprintf("%t", opt.PubliclyAccessible),
+		})
+		modify.PubliclyAccessible = aws.Bool(opt.PubliclyAccessible)
+		changed = true
+	}
+
+	if opt.CACertificateIdentifier != "" {
+		if currentCA := aws.ToString(instance.CACertificateIdentifier); currentCA != opt.CACertificateIdentifier {
+			drifts = append(drifts, rdsDrift{Field: "ca_certificate_identifier", Current: currentCA, Desired: opt.CACertificateIdentifier})
+			modify.CACertificateIdentifier = aws.String(opt.CACertificateIdentifier)
+			changed = true
+		}
+	}
+
+	desiredRetention := backupRetentionOrDefault(opt.BackupRetentionPeriod)
+	if currentRetention := aws.ToInt32(instance.BackupRetentionPeriod); currentRetention != desiredRetention {
+		drifts = append(drifts, rdsDrift{
+			Field:   "backup_retention_period",
+			Current: fmt.Sprintf("%d", currentRetention),
+			Desired: fmt.Sprintf("%d", desiredRetention),
+		})
+		modify.BackupRetentionPeriod = aws.Int32(desiredRetention)
+		changed = true
+	}
+
+	if opt.PreferredBackupWindow != "" && aws.ToString(instance.PreferredBackupWindow) != opt.PreferredBackupWindow {
+		drifts = append(drifts, rdsDrift{
+			Field:   "preferred_backup_window",
+			Current: aws.ToString(instance.PreferredBackupWindow),
+			Desired: opt.PreferredBackupWindow,
+		})
+		modify.PreferredBackupWindow = aws.String(opt.PreferredBackupWindow)
+		changed = true
+	}
+
+	if opt.PreferredMaintenanceWindow != "" && !strings.EqualFold(aws.ToString(instance.PreferredMaintenanceWindow), opt.PreferredMaintenanceWindow) {
+		drifts = append(drifts, rdsDrift{
+			Field:   "preferred_maintenance_window",
+			Current: aws.ToString(instance.PreferredMaintenanceWindow),
+			Desired: opt.PreferredMaintenanceWindow,
+		})
+		modify.PreferredMaintenanceWindow = aws.String(opt.PreferredMaintenanceWindow)
+		changed = true
+	}
+
+	if current := aws.ToBool(instance.CopyTagsToSnapshot); current != opt.CopyTagsToSnapshot {
+		drifts = append(drifts, rdsDrift{
+			Field:   "copy_tags_to_snapshot",
+			Current: fmt.Sprintf("%t", current),
+			Desired: fmt.Sprintf("%t", opt.CopyTagsToSnapshot),
+		})
+		modify.CopyTagsToSnapshot = aws.Bool(opt.CopyTagsToSnapshot)
+		changed = true
+	}
+
+	if current := aws.ToBool(instance.AutoMinorVersionUpgrade); current != opt.AutoMinorVersionUpgrade {
+		drifts = append(drifts, rdsDrift{
+			Field:   "auto_minor_version_upgrade",
+			Current: fmt.Sprintf("%t", current),
+			Desired: fmt.Sprintf("%t", opt.AutoMinorVersionUpgrade),
+		})
+		modify.AutoMinorVersionUpgrade = aws.Bool(opt.AutoMinorVersionUpgrade)
+		changed = true
+	}
+
+	if current := aws.ToBool(instance.DeletionProtection); current != opt.DeletionProtection {
+		drifts = append(drifts, rdsDrift{
+			Field:   "deletion_protection",
+			Current: fmt.Sprintf("%t", current),
+			Desired: fmt.Sprintf("%t", opt.DeletionProtection),
+		})
+		modify.DeletionProtection = aws.Bool(opt.DeletionProtection)
+		changed = true
+	}
+
+	observability, observabilityChanged := observabilityDrift(instance, opt, modify)
+	drifts = append(drifts, observability...)
+	changed = changed || observabilityChanged
+
+	if !changed {
+		return drifts, nil
+	}
+	return drifts, modify
+}
+
+// reconcileRDSInstance reports drift between the options and an existing
+// instance and, when reconcile is enabled, applies it via ModifyDBInstance.
+// It returns the (possibly refreshed) instance description.
+func reconcileRDSInstance(ctx context.Context, cfg aws.Config, client *rds.Client, instance *rdstypes.DBInstance, opt structs.Options, region string) (*rdstypes.DBInstance, error) {
+	name := aws.ToString(instance.DBInstanceIdentifier)
+
+	drifts, modify := detectRDSDrift(instance, opt)
+	if len(drifts) == 0 {
+		helpers.Debug("RDS instance %s matches the requested configuration", name)
+		return instance, nil
+	}
+
+	for _, d := range drifts {
+		helpers.Info("drift detected on RDS instance %s: %s is %s, want %s", name, d.Field, d.Current, d.Desired)
+	}
+
+	if !opt.Reconcile {
+		helpers.Info("reconcile is disabled; set reconcile=true to apply %d drifted setting(s) to %s", len(drifts), name)
+		return instance, nil
+	}
+	if modify == nil {
+		return instance, nil
+	}
+
+	if modify.DBInstanceClass != nil || aws.ToBool(modify.MultiAZ) {
+		err := checkOrderable(
+			ctx,
+			client,
+			opt,
+			region,
+			aws.ToString(instance.Engine),
+			aws.ToString(instance.EngineVersion),
+			instanceClassOrDefault(opt.InstanceClass),
+			opt.MultiAZ,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if aws.ToInt32(modify.MonitoringInterval) > 0 {
+		roleARN, err := monitoringRoleARN(ctx, cfg, opt)
+		if err != nil {
+			helpers.Error("preparing Enhanced Monitoring role failed: %v", err)
+			return nil, err
+		}
+		modify.MonitoringRoleArn = aws.String(roleARN)
+	}
+
+	helpers.Info("reconciling RDS instance %s (apply_immediately=%t)", name, opt.ApplyImmediately)
+
+	_, err := client.ModifyDBInstance(ctx, modify)
+	if err != nil {
+		helpers.Error("modify DB instance failed: %v", err)
+		return nil, err
+	}
+
+	if !opt.ApplyImmediately {
+		helpers.Info("changes to %s are pending until the next maintenance window", name)
+		return instance, nil
+	}
+
+	// Wait until the modification has been applied
+	if err := waitForRDSModification(ctx, client, name, aws.ToString(instance.DBInstanceStatus), 30*time.Minute); err != nil {
+		helpers.Error("waiting for DB instance modification failed: %v", err)
+		return nil, err
+	}
+
+	refreshed, err := describeRDSInstance(ctx, client, name)
+	if err != nil {
+		helpers.Error("describe DB instance after modification failed: %v", err)
+		return nil, err
+	}
+
+	helpers.Info("RDS instance %s reconciled", name)
+	return refreshed, nil
+}
+
+// waitForRDSModification waits until an immediately applied modification has
+// finished. Right after ModifyDBInstance the instance still reports the status
+// it had before, so waiting for "available" straight away returns at once. It
+// first waits for the modification to start (the status leaves before) or to
+// have been applied in place (nothing is pending any more), then for the
+// instance to become available.
+func waitForRDSModification(ctx context.Context, client *rds.Client, name, before string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		instance, err := describeRDSInstance(ctx, client, name)
+		if err != nil {
+			return err
+		}
+
+		status := aws.ToString(instance.DBInstanceStatus)
+		if status != before {
+			break
+		}
+		if instance.PendingModifiedValues == nil || reflect.ValueOf(*instance.PendingModifiedValues).IsZero() {
+			return nil
+		}
+		helpers.Debug("RDS instance %s is still %s, waiting for the modification to start", name, status)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for the modification of DB instance %s to start", name)
+		case <-ticker.C:
+		}
+	}
+
+	return waitForRDSStatus(ctx, client, name, "available", timeout)
+}
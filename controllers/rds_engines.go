@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// engineSpec describes how an RDS engine is created, connected to and exported.
+type engineSpec struct {
+	Name          string // RDS engine identifier
+	Family        string // postgres, mysql, sqlserver, oracle or db2
+	Port          int
+	LogExports    []string
+	LicenseModels []string // accepted license models, the first one is the default
+
+	// CreatesDatabase is false for engines whose CreateDBInstance rejects
+	// DBName (SQL Server); the database is created over SQL instead.
+	CreatesDatabase bool
+	DefaultDBName   string
+	DBNamePattern   *regexp.Regexp
+	DBNameRule      string
+}
+
+var (
+	sqlDBNamePattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,62}$`)
+	oracleDBNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]{0,7}$`)
+	db2DBNamePattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]{0,7}$`)
+)
+
+func postgresSpec(name string) engineSpec {
+	return engineSpec{
+		Name:            name,
+		Family:          "postgres",
+		Port:            5432,
+		LogExports:      []string{"postgresql", "upgrade"},
+		LicenseModels:   []string{"postgresql-license"},
+		CreatesDatabase: true,
+		DefaultDBName:   "app",
+		DBNamePattern:   sqlDBNamePattern,
+		DBNameRule:      "1-63 letters, digits or underscores, starting with a letter",
+	}
+}
+
+func mysqlSpec(name string) engineSpec {
+	return engineSpec{
+		Name:            name,
+		Family:          "mysql",
+		Port:            3306,
+		LogExports:      []string{"audit", "error", "general", "slowquery"},
+		LicenseModels:   []string{"general-public-license"},
+		CreatesDatabase: true,
+		DefaultDBName:   "app",
+		DBNamePattern:   sqlDBNamePattern,
+		DBNameRule:      "1-63 letters, digits or underscores, starting with a letter",
+	}
+}
+
+func sqlServerSpec(name string) engineSpec {
+	return engineSpec{
+		Name:            name,
+		Family:          "sqlserver",
+		Port:            1433,
+		LogExports:      []string{"agent", "error"},
+		LicenseModels:   []string{"license-included"},
+		CreatesDatabase: false,
+		DefaultDBName:   "app",
+		DBNamePattern:   sqlDBNamePattern,
+		DBNameRule:      "1-63 letters, digits or underscores, starting with a letter",
+	}
+}
+
+func oracleSpec(name string, licenseModels ...string) engineSpec {
+	return engineSpec{
+		Name:            name,
+		Family:          "oracle",
+		Port:            1521,
+		LogExports:      []string{"alert", "audit", "listener", "trace", "oemagent"},
+		LicenseModels:   licenseModels,
+		CreatesDatabase: true,
+		DefaultDBName:   "ORCL",
+		DBNamePattern:   oracleDBNamePattern,
+		DBNameRule:      "1-8 letters or digits, starting with a letter (it becomes the SID)",
+	}
+}
+
+func db2Spec(name string) engineSpec {
+	return engineSpec{
+		Name:            name,
+		Family:          "db2",
+		Port:            50000,
+		LogExports:      []string{"diag.log", "notify.log"},
+		LicenseModels:   []string{"marketplace-license", "bring-your-own-license"},
+		CreatesDatabase: true,
+		DefaultDBName:   "APP",
+		DBNamePattern:   db2DBNamePattern,
+		DBNameRule:      "1-8 letters or digits, starting with a letter",
+	}
+}
+
+// rdsEngines are the engines the provider knows how to create and connect to.
+var rdsEngines = map[string]engineSpec{
+	"postgres":       postgresSpec("postgres"),
+	"mysql":          mysqlSpec("mysql"),
+	"mariadb":        mysqlSpec("mariadb"),
+	"sqlserver-ex":   sqlServerSpec("sqlserver-ex"),
+	"sqlserver-web":  sqlServerSpec("sqlserver-web"),
+	"sqlserver-se":   sqlServerSpec("sqlserver-se"),
+	"sqlserver-ee":   sqlServerSpec("sqlserver-ee"),
+	"oracle-se2":     oracleSpec("oracle-se2", "license-included", "bring-your-own-license"),
+	"oracle-se2-cdb": oracleSpec("oracle-se2-cdb", "license-included", "bring-your-own-license"),
+	"oracle-ee":      oracleSpec("oracle-ee", "bring-your-own-license"),
+	"oracle-ee-cdb":  oracleSpec("oracle-ee-cdb", "bring-your-own-license"),
+	"db2-se":         db2Spec("db2-se"),
+	"db2-ae":         db2Spec("db2-ae"),
+}
+
+// rdsEngineAliases maps shorthand engine names onto RDS engine identifiers.
+var rdsEngineAliases = map[string]string{
+	"postgresql": "postgres",
+	"sqlserver":  "sqlserver-ex",
+	"oracle":     "oracle-se2",
+	"db2":        "db2-se",
+}
+
+// lookupEngine resolves an engine option (or alias) to its spec.
+func lookupEngine(engine string) (engineSpec, error) {
+	name := strings.ToLower(strings.TrimSpace(engine))
+	if alias, ok := rdsEngineAliases[name]; ok {
+		name = alias
+	}
+
+	spec, ok := rdsEngines[name]
+	if !ok {
+		supported := make([]string, 0, len(rdsEngines))
+		for n := range rdsEngines {
+			supported = append(supported, n)
+		}
+		sort.Strings(supported)
+		return engineSpec{}, fmt.Errorf("unsupported engine %q (supported: %s)", engine, strings.Join(supported, ", "))
+	}
+	return spec, nil
+}
+
+// engineSpecFor returns the spec of an engine reported by RDS, falling back to
+// postgres defaults for engines the provider does not model.
+func engineSpecFor(engine string) engineSpec {
+	spec, err := lookupEngine(engine)
+	if err != nil {
+		spec = postgresSpec(engine)
+		spec.Family = ""
+	}
+	return spec
+}
+
+// licenseModel returns the requested license model, or the engine's default.
+func (s engineSpec) licenseModel(requested string) (string, error) {
+	if requested == "" {
+		return s.LicenseModels[0], nil
+	}
+	if !slices.Contains(s.LicenseModels, requested) {
+		return "", fmt.Errorf("license_model %s is not available for %s (supported: %s)", requested, s.Name, strings.Join(s.LicenseModels, ", "))
+	}
+	return requested, nil
+}
+
+// db2LicenseParameters are the parameters RDS needs before it creates a Db2
+// instance with bring-your-own-license.
+var db2LicenseParameters = []string{"rds.ibm_customer_id", "rds.ibm_site_id"}
+
+// checkDb2License refuses a bring-your-own-license Db2 instance whose
+// db_parameter_group does not carry the IBM customer and site IDs, which RDS
+// would only reject after the create call.
+func checkDb2License(ctx context.Context, client *rds.Client, parameterGroup string) error {
+	if parameterGroup == "" {
+		return fmt.Errorf("license_model bring-your-own-license for Db2 needs a db_parameter_group that sets %s (or use marketplace-license)", strings.Join(db2LicenseParameters, " and "))
+	}
+
+	set := map[string]bool{}
+	paginator := rds.NewDescribeDBParametersPaginator(client, &rds.DescribeDBParametersInput{
+		DBParameterGroupName: aws.String(parameterGroup),
+		Source:               aws.String("user"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			var notFound *rdstypes.DBParameterGroupNotFoundFault
+			if errors.As(err, &notFound) {
+				return fmt.Errorf("db_parameter_group %s does not exist", parameterGroup)
+			}
+			return err
+		}
+		for _, p := range page.Parameters {
+			if aws.ToString(p.ParameterValue) != "" {
+				set[aws.ToString(p.ParameterName)] = true
+			}
+		}
+	}
+
+	var missing []string
+	for _, name := range db2LicenseParameters {
+		if !set[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("db_parameter_group %s does not set %s, which Db2 bring-your-own-license requires", parameterGroup, strings.Join(missing, " and "))
+	}
+	return nil
+}
+
+// validateDBName checks db_name against the engine's naming rules.
+func (s engineSpec) validateDBName(dbName string) error {
+	if !s.DBNamePattern.MatchString(dbName) {
+		return fmt.Errorf("db_name %q is not valid for %s: %s", dbName, s.Name, s.DBNameRule)
+	}
+	return nil
+}
+
+// oracleSID is the SID of an Oracle instance: the database name, or RDSCDB for
+// the container database of the multitenant (-cdb) engines.
+func (s engineSpec) oracleSID(dbName string) string {
+	if strings.HasSuffix(s.Name, "-cdb") {
+		return "RDSCDB"
+	}
+	return strings.ToUpper(dbName)
+}
+
+// dsn formats the connection string the engine's client libraries expect.
+// tlsQuery carries the certificate verification parameters, if any.
+func (s engineSpec) dsn(env rdsEnv, oracleConnectBy string, tlsQuery url.Values) string {
+	host := net.JoinHostPort(env.Host, strconv.Itoa(env.Port))
+
+	switch s.Family {
+	case "sqlserver":
+		query := url.Values{}
+		query.Set("database", env.DBName)
+		for k, v := range tlsQuery {
+			query[k] = v
+		}
+		u := url.URL{Scheme: "sqlserver", User: url.UserPassword(env.Username, env.Password), Host: host, RawQuery: query.Encode()}
+		return u.String()
+
+	case "oracle":
+		u := url.URL{Scheme: "oracle", User: url.UserPassword(env.Username, env.Password), Host: host}
+		if strings.ToLower(oracleConnectBy) == "sid" {
+			u.RawQuery = url.Values{"SID": {s.oracleSID(env.DBName)}}.Encode()
+		} else {
+			u.Path = "/" + env.DBName
+		}
+		return u.String()
+
+	case "db2":
+		dsn := fmt.Sprintf(
+			"DATABASE=%s;HOSTNAME=%s;PORT=%d;PROTOCOL=TCPIP;UID=%s;PWD=%s;",
+			env.DBName,
+			env.Host,
+			env.Port,
+			env.Username,
+			env.Password,
+		)
+		if tlsQuery != nil {
+			dsn += "Security=SSL;"
+			if cert := tlsQuery.Get("SSLServerCertificate"); cert != "" {
+				dsn += "SSLServerCertificate=" + cert + ";"
+			}
+		}
+		return dsn
+
+	default:
+		u := url.URL{
+			Scheme:   s.Name,
+			User:     url.UserPassword(env.Username, env.Password),
+			Host:     host,
+			Path:     "/" + env.DBName,
+			RawQuery: tlsQuery.Encode(),
+		}
+		return u.String()
+	}
+}
+
+// ensureSQLServerDatabase creates the service's database on a SQL Server
+// instance, since RDS does not create one for that engine.
+func ensureSQLServerDatabase(ctx context.Context, master rdsConnection, dbName string) error {
+	db, err := openAdminConnection(ctx, master.withDatabase(adminDatabase("sqlserver")))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	dbExists, err := exists(ctx, db, "SELECT 1 FROM sys.databases WHERE name = @p1", dbName)
+	if err != nil || dbExists {
+		return err
+	}
+	return execAll(ctx, db, "CREATE DATABASE "+quoteIdent("sqlserver", dbName))
+}
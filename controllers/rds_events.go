@@ -0,0 +1,287 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// rdsEventCategories are the event categories RDS publishes for DB instances.
+var rdsEventCategories = []string{
+	"availability",
+	"backup",
+	"configuration change",
+	"creation",
+	"deletion",
+	"failover",
+	"failure",
+	"low storage",
+	"maintenance",
+	"notification",
+	"read replica",
+	"recovery",
+	"restoration",
+	"security",
+	"security patching",
+}
+
+// defaultWatchedEventCategories cover failovers, reboots (availability),
+// storage-full and maintenance events.
+var defaultWatchedEventCategories = []string{"availability", "failover", "failure", "low storage", "maintenance"}
+
+// resolveEventCategories validates the requested categories. "all" returns nil,
+// which RDS treats as every category.
+func resolveEventCategories(requested []string) ([]string, error) {
+	var out []string
+	for _, r := range requested {
+		r = strings.ToLower(r)
+		if r == "all" {
+			return nil, nil
+		}
+		if !slices.Contains(rdsEventCategories, r) {
+			return nil, fmt.Errorf("unknown RDS event category %q (supported: all, %s)", r, strings.Join(rdsEventCategories, ", "))
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// eventSubscriptionName is the RDS event subscription of a service.
+func eventSubscriptionName(name string) string {
+	return name + "-events"
+}
+
+// eventTopicName is the SNS topic created when events_sns_topic_arn is empty.
+func eventTopicName(opt structs.Options, name string) string {
+	project := helpers.WithFallbackValue(opt.Project, "compose")
+	return strings.ToLower(strings.ReplaceAll(fmt.Sprintf("%s-%s-rds-events", project, name), "_", "-"))
+}
+
+// ensureRDSEventSubscription subscribes an SNS topic (created when none is
+// given) to the instance's events.
+func ensureRDSEventSubscription(ctx context.Context, cfg aws.Config, client *rds.Client, opt structs.Options, name string) error {
+	categories, err := resolveEventCategories(opt.Events)
+	if err != nil {
+		helpers.Error("invalid events option: %v", err)
+		return err
+	}
+
+	topicARN := opt.EventsSNSTopicARN
+	if topicARN == "" {
+		// CreateTopic is idempotent and returns the existing topic's ARN
+		out, err := sns.NewFromConfig(cfg).CreateTopic(ctx, &sns.CreateTopicInput{
+			Name: aws.String(eventTopicName(opt, name)),
+		})
+		if err != nil {
+			helpers.Error("create SNS topic for RDS events failed: %v", err)
+			return err
+		}
+		topicARN = aws.ToString(out.TopicArn)
+	}
+
+	subscription := eventSubscriptionName(name)
+
+	_, err = client.CreateEventSubscription(ctx, &rds.CreateEventSubscriptionInput{
+		SubscriptionName: aws.String(subscription),
+		SnsTopicArn:      aws.String(topicARN),
+		SourceType:       aws.String(string(rdstypes.SourceTypeDbInstance)),
+		SourceIds:        []string{name},
+		EventCategories:  categories,
+		Enabled:          aws.Bool(true),
+	})
+	if err != nil {
+		var exists *rdstypes.SubscriptionAlreadyExistFault
+		if !errors.As(err, &exists) {
+			helpers.Error("create RDS event subscription failed: %v", err)
+			return err
+		}
+
+		// An empty list on modify keeps the subscription's current categories
+		// rather than meaning all of them, so "all" is spelled out here
+		modifyCategories := categories
+		if len(modifyCategories) == 0 {
+			modifyCategories = rdsEventCategories
+		}
+
+		_, err = client.ModifyEventSubscription(ctx, &rds.ModifyEventSubscriptionInput{
+			SubscriptionName: aws.String(subscription),
+			SnsTopicArn:      aws.String(topicARN),
+			SourceType:       aws.String(string(rdstypes.SourceTypeDbInstance)),
+			EventCategories:  modifyCategories,
+			Enabled:          aws.Bool(true),
+		})
+		if err != nil {
+			helpers.Error("update RDS event subscription failed: %v", err)
+			return err
+		}
+	}
+
+	helpers.Info(
+		"RDS event subscription %s publishes %s events of %s to %s",
+		subscription,
+		helpers.WithFallbackValue(strings.Join(categories, ", "), "all"),
+		name,
+		topicARN,
+	)
+	helpers.Setenv("RDS_EVENTS_SNS_TOPIC_ARN", topicARN)
+
+	return nil
+}
+
+// deleteRDSEventSubscription removes the service's event subscription, and the
+// SNS topic when it was created by up.
+func deleteRDSEventSubscription(ctx context.Context, cfg aws.Config, client *rds.Client, opt structs.Options, name string) error {
+	subscription := eventSubscriptionName(name)
+
+	describeOut, err := client.DescribeEventSubscriptions(ctx, &rds.DescribeEventSubscriptionsInput{
+		SubscriptionName: aws.String(subscription),
+	})
+	if err != nil {
+		var notFound *rdstypes.SubscriptionNotFoundFault
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		helpers.Error("describe RDS event subscription failed: %v", err)
+		return err
+	}
+
+	helpers.Info("deleting RDS event subscription %s", subscription)
+
+	_, err = client.DeleteEventSubscription(ctx, &rds.DeleteEventSubscriptionInput{
+		SubscriptionName: aws.String(subscription),
+	})
+	if err != nil {
+		helpers.Error("delete RDS event subscription failed: %v", err)
+		return err
+	}
+
+	if opt.EventsSNSTopicARN != "" || len(describeOut.EventSubscriptionsList) == 0 {
+		return nil
+	}
+
+	topicARN := aws.ToString(describeOut.EventSubscriptionsList[0].SnsTopicArn)
+	helpers.Info("deleting SNS topic %s", topicARN)
+
+	_, err = sns.NewFromConfig(cfg).DeleteTopic(ctx, &sns.DeleteTopicInput{
+		TopicArn: aws.String(topicARN),
+	})
+	if err != nil {
+		helpers.Error("delete SNS topic failed: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// WatchRDSEvents polls DescribeEvents for the service's instance and streams
+// every new event as JSONL until ctx is cancelled.
+func WatchRDSEvents(ctx context.Context, opt structs.Options) error {
+	region := helpers.WithFallbackValue(opt.Region, "ap-southeast-1")
+	name := helpers.WithFallbackValue(opt.Name, "rds")
+
+	requested := opt.Events
+	if len(requested) == 0 {
+		requested = defaultWatchedEventCategories
+	}
+	categories, err := resolveEventCategories(requested)
+	if err != nil {
+		helpers.Error("invalid events option: %v", err)
+		return err
+	}
+
+	interval := time.Duration(opt.WatchInterval) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	cfg, err := helpers.LoadAWSConfig(ctx, region)
+	if err != nil {
+		helpers.Error("unable to load AWS config: %v", err)
+		return err
+	}
+
+	client := rds.NewFromConfig(cfg)
+
+	since := time.Now().UTC().Add(-time.Duration(opt.WatchSince) * time.Minute)
+	seen := map[string]bool{}
+
+	helpers.Info(
+		"watching RDS events of %s in %s (categories=%s, every %s)",
+		name,
+		region,
+		helpers.WithFallbackValue(strings.Join(categories, ", "), "all"),
+		interval,
+	)
+
+	for {
+		paginator := rds.NewDescribeEventsPaginator(client, &rds.DescribeEventsInput{
+			SourceIdentifier: aws.String(name),
+			SourceType:       rdstypes.SourceTypeDbInstance,
+			EventCategories:  categories,
+			StartTime:        aws.Time(since),
+		})
+
+		var events []rdstypes.Event
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				helpers.Error("describe RDS events failed: %v", err)
+				return err
+			}
+			events = append(events, page.Events...)
+		}
+
+		// StartTime is inclusive, so events at the last timestamp come back on
+		// the next poll; only those need remembering.
+		latest := since
+		for _, e := range events {
+			date := aws.ToTime(e.Date)
+			key := date.Format(time.RFC3339Nano) + " " + aws.ToString(e.Message)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			helpers.Event(
+				"%s [%s] %s: %s",
+				date.UTC().Format(time.RFC3339),
+				strings.Join(e.EventCategories, ", "),
+				aws.ToString(e.SourceIdentifier),
+				aws.ToString(e.Message),
+			)
+
+			if date.After(latest) {
+				latest = date
+			}
+		}
+
+		if latest.After(since) {
+			prefix := latest.Format(time.RFC3339Nano) + " "
+			for key := range seen {
+				if !strings.HasPrefix(key, prefix) {
+					delete(seen, key)
+				}
+			}
+			since = latest
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+)
+
+// migrationsTable is the bookkeeping table that records applied SQL files.
+const migrationsTable = "aws_compose_migrations"
+
+// sqlFile is a single SQL file to apply, identified by a stable key.
+type sqlFile struct {
+	Key  string
+	Path string
+}
+
+// collectSQLFiles lists the *.sql files of initDir (not its subdirectories) in
+// lexical order followed by the seed files in the order they were given.
+func collectSQLFiles(initDir string, seedFiles []string) ([]sqlFile, error) {
+	var files []sqlFile
+
+	if initDir != "" {
+		entries, err := os.ReadDir(initDir)
+		if err != nil {
+			return nil, fmt.Errorf("read init_sql_dir %s: %w", initDir, err)
+		}
+
+		var names []string
+		for _, e := range entries {
+			if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".sql") {
+				continue
+			}
+			names = append(names, e.Name())
+		}
+		sort.Strings(names)
+
+		for _, n := range names {
+			files = append(files, sqlFile{Key: "init/" + n, Path: filepath.Join(initDir, n)})
+		}
+	}
+
+	seen := map[string]string{}
+	for _, f := range seedFiles {
+		key, err := seedFileKey(f)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[key]; ok {
+			return nil, fmt.Errorf("seed_files %s and %s resolve to the same file", other, f)
+		}
+		seen[key] = f
+
+		files = append(files, sqlFile{Key: key, Path: f})
+	}
+
+	return files, nil
+}
+
+// seedFileKey identifies a seed file by its slash-separated path relative to
+// the working directory (the Compose project), so files with the same name in
+// different directories are tracked separately. Files outside it use their
+// absolute path.
+func seedFileKey(file string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", fmt.Errorf("resolve seed file %s: %w", file, err)
+	}
+
+	key := abs
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			key = rel
+		}
+	}
+	return "seed/" + strings.TrimPrefix(filepath.ToSlash(key), "/"), nil
+}
+
+// migrationsTableDDL returns the CREATE TABLE statement for the bookkeeping table.
+func migrationsTableDDL(family string) string {
+	switch family {
+	case "postgres":
+		return `CREATE TABLE IF NOT EXISTS ` + migrationsTable + ` (
+			filename VARCHAR(255) PRIMARY KEY,
+			checksum VARCHAR(64) NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		)`
+	case "sqlserver":
+		return `IF OBJECT_ID(N'` + migrationsTable + `', N'U') IS NULL
+		CREATE TABLE ` + migrationsTable + ` (
+			filename NVARCHAR(255) PRIMARY KEY,
+			checksum VARCHAR(64) NOT NULL,
+			applied_at DATETIME2 NOT NULL DEFAULT SYSUTCDATETIME()
+		)`
+	default:
+		return `CREATE TABLE IF NOT EXISTS ` + migrationsTable + ` (
+			filename VARCHAR(255) PRIMARY KEY,
+			checksum VARCHAR(64) NOT NULL,
+			applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`
+	}
+}
+
+// appliedMigrations returns the checksum of every file already applied.
+func appliedMigrations(ctx context.Context, db *sql.DB) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT filename, checksum FROM "+migrationsTable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[string]string{}
+	for rows.Next() {
+		var name, sum string
+		if err := rows.Scan(&name, &sum); err != nil {
+			return nil, err
+		}
+		applied[name] = sum
+	}
+	return applied, rows.Err()
+}
+
+// applyRDSMigrations applies the init_sql_dir and seed_files SQL exactly once,
+// recording each applied file in the bookkeeping table.
+func applyRDSMigrations(ctx context.Context, conn rdsConnection, initDir string, seedFiles []string) error {
+	files, err := collectSQLFiles(initDir, seedFiles)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	family := sqlFamily(conn.Engine)
+	if family == "" {
+		return fmt.Errorf("init_sql_dir / seed_files are not supported for engine %s", conn.Engine)
+	}
+
+	db, err := openRDSDatabase(conn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	helpers.Info("waiting for %s to accept connections before applying SQL files", conn.address())
+	if err := waitForRDSConnection(ctx, db, 10*time.Minute); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, migrationsTableDDL(family)); err != nil {
+		return fmt.Errorf("create %s table: %w", migrationsTable, err)
+	}
+
+	applied, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return fmt.Errorf("read %s table: %w", migrationsTable, err)
+	}
+
+	insert := fmt.Sprintf(
+		"INSERT INTO %s (filename, checksum) VALUES (%s, %s)",
+		migrationsTable,
+		placeholder(family, 1),
+		placeholder(family, 2),
+	)
+
+	count := 0
+	for _, f := range files {
+		content, err := os.ReadFile(f.Path)
+		if err != nil {
+			return fmt.Errorf("read SQL file %s: %w", f.Path, err)
+		}
+
+		digest := sha256.Sum256(content)
+		checksum := hex.EncodeToString(digest[:])
+
+		if prev, ok := applied[f.Key]; ok {
+			if prev != checksum {
+				helpers.Info("SQL file %s changed since it was applied; it will not be re-applied", f.Key)
+			}
+			continue
+		}
+
+		helpers.Info("applying SQL file %s", f.Key)
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin transaction for %s: %w", f.Key, err)
+		}
+		if _, err := tx.ExecContext(ctx, string(content)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("apply SQL file %s: %w", f.Key, err)
+		}
+		if _, err := tx.ExecContext(ctx, insert, f.Key, checksum); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("record SQL file %s: %w", f.Key, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit SQL file %s: %w", f.Key, err)
+		}
+		count++
+	}
+
+	helpers.Info("applied %d SQL file(s) (%d already applied)", count, len(files)-count)
+	return nil
+}
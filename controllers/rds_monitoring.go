@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// rdsMonitoringRoleName is the role RDS itself creates from the console, so we
+// reuse it when it already exists in the account.
+const rdsMonitoringRoleName = "rds-monitoring-role"
+
+const rdsMonitoringPolicyARN = "arn:aws:iam::aws:policy/service-role/AmazonRDSEnhancedMonitoringRole"
+
+const rdsMonitoringTrustPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {"Service": "monitoring.rds.amazonaws.com"},
+      "Action": "sts:AssumeRole"
+    }
+  ]
+}`
+
+// validMonitoringIntervals are the only Enhanced Monitoring intervals RDS accepts.
+var validMonitoringIntervals = []int{0, 1, 5, 10, 15, 30, 60}
+
+// resolveLogExports expands "all" and validates the requested log types against
+// what the engine supports.
+func resolveLogExports(engine string, requested []string) ([]string, error) {
+	supported := engineSpecFor(engine).LogExports
+
+	var out []string
+	for _, r := range requested {
+		r = strings.ToLower(r)
+		if r == "all" {
+			return supported, nil
+		}
+		if !slices.Contains(supported, r) {
+			return nil, fmt.Errorf("log export %q is not supported for engine %s (supported: %s)", r, engine, strings.Join(supported, ", "))
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// prepareRDSObservability validates the Performance Insights, Enhanced
+// Monitoring and log export options and fills in derived values. Log types are
+// checked against engine, which is the existing instance's engine when there
+// is one.
+func prepareRDSObservability(engine string, opt *structs.Options) error {
+	if !slices.Contains(validMonitoringIntervals, opt.MonitoringInterval) {
+		return fmt.Errorf("monitoring_interval must be one of 0, 1, 5, 10, 15, 30 or 60 (got %d)", opt.MonitoringInterval)
+	}
+
+	if opt.PerformanceInsights && opt.PerformanceInsightsRetention <= 0 {
+		opt.PerformanceInsightsRetention = 7
+	}
+
+	logExports, err := resolveLogExports(engine, opt.LogExports)
+	if err != nil {
+		return err
+	}
+	opt.LogExports = logExports
+
+	return nil
+}
+
+// monitoringRoleARN returns monitoring_role_arn, or the ARN of the Enhanced
+// Monitoring role, creating it when needed. It is only called once a create or
+// modify request is about to enable Enhanced Monitoring.
+func monitoringRoleARN(ctx context.Context, cfg aws.Config, opt structs.Options) (string, error) {
+	if opt.MonitoringRoleARN != "" {
+		return opt.MonitoringRoleARN, nil
+	}
+	return ensureRDSMonitoringRole(ctx, iam.NewFromConfig(cfg))
+}
+
+// ensureRDSMonitoringRole returns the ARN of the Enhanced Monitoring role,
+// creating it and attaching the AWS managed policy if it does not exist.
+func ensureRDSMonitoringRole(ctx context.Context, client *iam.Client) (string, error) {
+	getOut, err := client.GetRole(ctx, &iam.GetRoleInput{
+		RoleName: aws.String(rdsMonitoringRoleName),
+	})
+	if err == nil {
+		helpers.Debug("reusing IAM role %s for Enhanced Monitoring", rdsMonitoringRoleName)
+		return aws.ToString(getOut.Role.Arn), nil
+	}
+
+	var notFound *iamtypes.NoSuchEntityException
+	if !errors.As(err, &notFound) {
+		return "", fmt.Errorf("get IAM role %s: %w", rdsMonitoringRoleName, err)
+	}
+
+	helpers.Info("creating IAM role %s for Enhanced Monitoring", rdsMonitoringRoleName)
+
+	createOut, err := client.CreateRole(ctx, &iam.CreateRoleInput{
+		RoleName:                 aws.String(rdsMonitoringRoleName),
+		AssumeRolePolicyDocument: aws.String(rdsMonitoringTrustPolicy),
+		Description:              aws.String("Allows RDS to send Enhanced Monitoring metrics to CloudWatch Logs"),
+	})
+	if err != nil {
+		return "", fmt.Errorf("create IAM role %s: %w", rdsMonitoringRoleName, err)
+	}
+
+	_, err = client.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
+		RoleName:  aws.String(rdsMonitoringRoleName),
+		PolicyArn: aws.String(rdsMonitoringPolicyARN),
+	})
+	if err != nil {
+		return "", fmt.Errorf("attach policy to IAM role %s: %w", rdsMonitoringRoleName, err)
+	}
+
+	// New roles take a moment to become visible to RDS
+	waiter := iam.NewRoleExistsWaiter(client)
+	if err := waiter.Wait(ctx, &iam.GetRoleInput{RoleName: aws.String(rdsMonitoringRoleName)}, 2*time.Minute); err != nil {
+		return "", fmt.Errorf("waiting for IAM role %s: %w", rdsMonitoringRoleName, err)
+	}
+
+	return aws.ToString(createOut.Role.Arn), nil
+}
+
+// applyObservabilityToCreate copies the observability options onto a create request.
+func applyObservabilityToCreate(input *rds.CreateDBInstanceInput, opt structs.Options) {
+	if opt.PerformanceInsights {
+		input.EnablePerformanceInsights = aws.Bool(true)
+		input.PerformanceInsightsRetentionPeriod = aws.Int32(int32(opt.PerformanceInsightsRetention))
+		if opt.PerformanceInsightsKMSKeyID != "" {
+			input.PerformanceInsightsKMSKeyId = aws.String(opt.PerformanceInsightsKMSKeyID)
+		}
+	}
+
+	if opt.MonitoringInterval > 0 {
+		input.MonitoringInterval = aws.Int32(int32(opt.MonitoringInterval))
+		input.MonitoringRoleArn = aws.String(opt.MonitoringRoleARN)
+	}
+
+	if len(opt.LogExports) > 0 {
+		input.EnableCloudwatchLogsExports = opt.LogExports
+	}
+}
+
+// observabilityDrift compares the observability options with the instance and
+// records any changes on the modify request. It reports whether anything changed.
+func observabilityDrift(instance *rdstypes.DBInstance, opt structs.Options, modify *rds.ModifyDBInstanceInput) ([]rdsDrift, bool) {
+	var drifts []rdsDrift
+	changed := false
+
+	if current := aws.ToBool(instance.PerformanceInsightsEnabled); current != opt.PerformanceInsights {
+		drifts = append(drifts, rdsDrift{
+			Field:   "performance_insights",
+			Current: fmt.Sprintf("%t", current),
+			Desired: fmt.Sprintf("%t", opt.PerformanceInsights),
+		})
+		modify.EnablePerformanceInsights = aws.Bool(opt.PerformanceInsights)
+		if opt.PerformanceInsights {
+			modify.PerformanceInsightsRetentionPeriod = aws.Int32(int32(opt.PerformanceInsightsRetention))
+			if opt.PerformanceInsightsKMSKeyID != "" {
+				modify.PerformanceInsightsKMSKeyId = aws.String(opt.PerformanceInsightsKMSKeyID)
+			}
+		}
+		changed = true
+	} else if opt.PerformanceInsights && aws.ToInt32(instance.PerformanceInsightsRetentionPeriod) != int32(opt.PerformanceInsightsRetention) {
+		drifts = append(drifts, rdsDrift{
+			Field:   "performance_insights_retention",
+			Current: fmt.Sprintf("%d", aws.ToInt32(instance.PerformanceInsightsRetentionPeriod)),
+			Desired: fmt.Sprintf("%d", opt.PerformanceInsightsRetention),
+		})
+		modify.EnablePerformanceInsights = aws.Bool(true)
+		modify.PerformanceInsightsRetentionPeriod = aws.Int32(int32(opt.PerformanceInsightsRetention))
+		changed = true
+	}
+
+	if current := aws.ToInt32(instance.MonitoringInterval); current != int32(opt.MonitoringInterval) {
+		drifts = append(drifts, rdsDrift{
+			Field:   "monitoring_interval",
+			Current: fmt.Sprintf("%d", current),
+			Desired: fmt.Sprintf("%d", opt.MonitoringInterval),
+		})
+		// The role is filled in by reconcile, only if the change is applied
+		modify.MonitoringInterval = aws.Int32(int32(opt.MonitoringInterval))
+		changed = true
+	}
+
+	var enable, disable []string
+	for _, l := range opt.LogExports {
+		if !slices.Contains(instance.EnabledCloudwatchLogsExports, l) {
+			enable = append(enable, l)
+		}
+	}
+	for _, l := range instance.EnabledCloudwatchLogsExports {
+		if !slices.Contains(opt.LogExports, l) {
+			disable = append(disable, l)
+		}
+	}
+	if len(enable) > 0 || len(disable) > 0 {
+		drifts = append(drifts, rdsDrift{
+			Field:   "log_exports",
+			Current: strings.Join(instance.EnabledCloudwatchLogsExports, ","),
+			Desired: strings.Join(opt.LogExports, ","),
+		})
+		modify.CloudwatchLogsExportConfiguration = &rdstypes.CloudwatchLogsExportConfiguration{
+			EnableLogTypes:  enable,
+			DisableLogTypes: disable,
+		}
+		changed = true
+	}
+
+	return drifts, changed
+}
@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+)
+
+// orderableCacheTTL is how long a cached DescribeOrderableDBInstanceOptions
+// result is trusted before it is fetched again.
+const orderableCacheTTL = 24 * time.Hour
+
+// orderableOption is one orderable instance class / storage combination.
+type orderableOption struct {
+	InstanceClass  string `json:"instance_class"`
+	EngineVersion  string `json:"engine_version"`
+	StorageType    string `json:"storage_type"`
+	MultiAZCapable bool   `json:"multi_az_capable"`
+}
+
+// orderableSnapshot is the on-disk format of the cache and of offline snapshots.
+type orderableSnapshot struct {
+	Region    string            `json:"region"`
+	Engine    string            `json:"engine"`
+	FetchedAt time.Time         `json:"fetched_at"`
+	Options   []orderableOption `json:"options"`
+}
+
+// orderableCachePath returns where the orderable options of a region / engine are cached.
+func orderableCachePath(region, engine string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "aws-compose-service", fmt.Sprintf("orderable-%s-%s.json", region, engine)), nil
+}
+
+func readOrderableSnapshot(path string) (*orderableSnapshot, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot orderableSnapshot
+	if err := json.Unmarshal(raw, &snapshot); err != nil {
+		return nil, fmt.Errorf("parse orderable snapshot %s: %w", path, err)
+	}
+	return &snapshot, nil
+}
+
+func writeOrderableSnapshot(path string, snapshot *orderableSnapshot) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	raw, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0o644)
+}
+
+// loadOrderableOptions returns the orderable options for the engine, from the
+// offline snapshot when one is given, from the local cache when it is fresh, or
+// from DescribeOrderableDBInstanceOptions otherwise.
+func loadOrderableOptions(ctx context.Context, client *rds.Client, region, engine, snapshotPath string) ([]orderableOption, error) {
+	if snapshotPath != "" {
+		snapshot, err := readOrderableSnapshot(snapshotPath)
+		if err != nil {
+			return nil, err
+		}
+		helpers.Debug("using offline orderable snapshot %s (fetched %s)", snapshotPath, snapshot.FetchedAt.Format(time.RFC3339))
+		return snapshot.Options, nil
+	}
+
+	cachePath, cacheErr := orderableCachePath(region, engine)
+	if cacheErr == nil {
+		if snapshot, err := readOrderableSnapshot(cachePath); err == nil && time.Since(snapshot.FetchedAt) < orderableCacheTTL {
+			return snapshot.Options, nil
+		}
+	}
+
+	helpers.Debug("fetching orderable instance options for %s in %s", engine, region)
+
+	var options []orderableOption
+	paginator := rds.NewDescribeOrderableDBInstanceOptionsPaginator(client, &rds.DescribeOrderableDBInstanceOptionsInput{
+		Engine: aws.String(engine),
+		Vpc:    aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("describe orderable DB instance options: %w", err)
+		}
+		for _, o := range page.OrderableDBInstanceOptions {
+			options = append(options, orderableOption{
+				InstanceClass:  aws.ToString(o.DBInstanceClass),
+				EngineVersion:  aws.ToString(o.EngineVersion),
+				StorageType:    aws.ToString(o.StorageType),
+				MultiAZCapable: aws.ToBool(o.MultiAZCapable),
+			})
+		}
+	}
+
+	if cacheErr == nil {
+		snapshot := &orderableSnapshot{Region: region, Engine: engine, FetchedAt: time.Now().UTC(), Options: options}
+		if err := writeOrderableSnapshot(cachePath, snapshot); err != nil {
+			helpers.Debug("caching orderable options failed: %v", err)
+		}
+	}
+
+	return options, nil
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// nearestInstanceClasses returns up to n supported classes closest to the requested one.
+func nearestInstanceClasses(requested string, supported []string, n int) []string {
+	sorted := slices.Clone(supported)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return levenshtein(requested, sorted[i]) < levenshtein(requested, sorted[j])
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// validateInstanceClass checks the class / engine version / Multi-AZ combination
+// against the orderable options and reports what the chosen class supports.
+func validateInstanceClass(options []orderableOption, engine, engineVersion, instanceClass string, multiAZ bool) error {
+	if len(options) == 0 {
+		return fmt.Errorf("no orderable instance options found for engine %s; check the engine name and region", engine)
+	}
+
+	var matching []orderableOption
+	classSet := map[string]bool{}
+	for _, o := range options {
+		if engineVersion != "" && o.EngineVersion != engineVersion && !strings.HasPrefix(o.EngineVersion, engineVersion+".") {
+			continue
+		}
+		classSet[o.InstanceClass] = true
+		if o.InstanceClass == instanceClass {
+			matching = append(matching, o)
+		}
+	}
+
+	if len(classSet) == 0 {
+		return fmt.Errorf("engine_version %s is not orderable for engine %s", engineVersion, engine)
+	}
+
+	if len(matching) == 0 {
+		classes := make([]string, 0, len(classSet))
+		for c := range classSet {
+			classes = append(classes, c)
+		}
+		sort.Strings(classes)
+
+		return fmt.Errorf(
+			"instance_class %s is not available for %s %s in this region; nearest supported classes: %s",
+			instanceClass,
+			engine,
+			helpers.WithFallbackValue(engineVersion, "(any version)"),
+			strings.Join(nearestInstanceClasses(instanceClass, classes, 5), ", "),
+		)
+	}
+
+	var storageTypes []string
+	multiAZCapable := false
+	for _, o := range matching {
+		if o.StorageType != "" && !slices.Contains(storageTypes, o.StorageType) {
+			storageTypes = append(storageTypes, o.StorageType)
+		}
+		multiAZCapable = multiAZCapable || o.MultiAZCapable
+	}
+	sort.Strings(storageTypes)
+
+	helpers.Info(
+		"instance_class %s is available for %s (storage types: %s, multi-AZ capable: %t)",
+		instanceClass,
+		engine,
+		strings.Join(storageTypes, ", "),
+		multiAZCapable,
+	)
+
+	if multiAZ && !multiAZCapable {
+		return fmt.Errorf("instance_class %s does not support Multi-AZ for %s; set multi_az=false or choose another class", instanceClass, engine)
+	}
+
+	return nil
+}
+
+// checkOrderable validates the instance class / engine / Multi-AZ combination
+// before anything is created or modified, unless validation is disabled.
+func checkOrderable(ctx context.Context, client *rds.Client, opt structs.Options, region, engine, engineVersion, instanceClass string, multiAZ bool) error {
+	if !opt.ValidateInstanceClass {
+		return nil
+	}
+
+	options, err := loadOrderableOptions(ctx, client, region, engine, opt.OrderableSnapshot)
+	if err != nil {
+		helpers.Error("loading orderable instance options failed: %v", err)
+		return err
+	}
+
+	if err := validateInstanceClass(options, engine, engineVersion, instanceClass, multiAZ); err != nil {
+		helpers.Error("%v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/InspectorGadget/aws-compose-service/structs"
+)
+
+func TestCheckOrderable(t *testing.T) {
+	opt := structs.Options{
+		ValidateInstanceClass: true,
+		OrderableSnapshot:     "testdata/orderable-postgres.json",
+	}
+
+	tests := []struct {
+		name          string
+		engineVersion string
+		instanceClass string
+		multiAZ       bool
+		wantErr       string
+	}{
+		{name: "orderable class", engineVersion: "16", instanceClass: "db.t3.micro"},
+		{name: "orderable class with Multi-AZ", instanceClass: "db.t3.micro", multiAZ: true},
+		{name: "class not orderable", instanceClass: "db.t3.mcro", wantErr: "nearest supported classes: db.t3.micro"},
+		{name: "class not orderable for version", engineVersion: "16", instanceClass: "db.m5.large", wantErr: "is not available"},
+		{name: "engine version not orderable", engineVersion: "12", instanceClass: "db.t3.micro", wantErr: "engine_version 12 is not orderable"},
+		{name: "Multi-AZ not supported", instanceClass: "db.t4g.micro", multiAZ: true, wantErr: "does not support Multi-AZ"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOrderable(context.Background(), nil, opt, "ap-southeast-1", "postgres", tt.engineVersion, tt.instanceClass, tt.multiAZ)
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckOrderableDisabled(t *testing.T) {
+	opt := structs.Options{OrderableSnapshot: "testdata/missing.json"}
+
+	if err := checkOrderable(context.Background(), nil, opt, "ap-southeast-1", "postgres", "", "db.x.unknown", true); err != nil {
+		t.Fatalf("validation is disabled, got error: %v", err)
+	}
+}
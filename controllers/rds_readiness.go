@@ -0,0 +1,202 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	mssql "github.com/microsoft/go-mssqldb"
+)
+
+// readinessFailure is the diagnosis of a single failed readiness attempt.
+type readinessFailure struct {
+	Stage     string // dns, tcp or ping
+	Reason    string
+	Hint      string
+	Retryable bool
+	Err       error
+}
+
+func (f *readinessFailure) Error() string {
+	return fmt.Sprintf("%s check failed (%s): %v", f.Stage, f.Reason, f.Err)
+}
+
+// readinessSettings controls the readiness probe run before env is exported.
+type readinessSettings struct {
+	Mode     string // none, tcp or ping
+	Timeout  time.Duration
+	Interval time.Duration
+}
+
+// diagnoseDialError classifies a DNS or TCP failure.
+func diagnoseDialError(err error) *readinessFailure {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return &readinessFailure{
+			Stage:     "dns",
+			Reason:    "name resolution failed",
+			Hint:      "the endpoint does not resolve yet; new RDS endpoints can take a few minutes to propagate",
+			Retryable: true,
+			Err:       err,
+		}
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return &readinessFailure{
+			Stage:     "tcp",
+			Reason:    "connection refused",
+			Hint:      "the host is reachable but nothing is listening on the port; the instance may still be starting",
+			Retryable: true,
+			Err:       err,
+		}
+	}
+
+	var netErr net.Error
+	if errors.Is(err, os.ErrDeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &readinessFailure{
+			Stage:     "tcp",
+			Reason:    "connection timed out",
+			Hint:      "check the security group inbound rules, publicly_accessible and the route from this machine to the VPC",
+			Retryable: true,
+			Err:       err,
+		}
+	}
+
+	return &readinessFailure{
+		Stage:     "tcp",
+		Reason:    "connection failed",
+		Hint:      "check network connectivity to the instance",
+		Retryable: true,
+		Err:       err,
+	}
+}
+
+// isAuthError reports whether a driver error means the credentials were rejected.
+func isAuthError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "28P01" || pgErr.Code == "28000"
+	}
+
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1045
+	}
+
+	var msErr mssql.Error
+	if errors.As(err, &msErr) {
+		return msErr.Number == 18456
+	}
+
+	return false
+}
+
+// diagnosePingError classifies an engine-level ping failure.
+func diagnosePingError(err error) *readinessFailure {
+	if isAuthError(err) {
+		return &readinessFailure{
+			Stage:     "ping",
+			Reason:    "authentication failed",
+			Hint:      "the username / password were rejected; check the credentials (a reused instance keeps its original master password)",
+			Retryable: false,
+			Err:       err,
+		}
+	}
+
+	if f := diagnoseDialError(err); f.Reason != "connection failed" {
+		f.Stage = "ping"
+		return f
+	}
+
+	return &readinessFailure{
+		Stage:     "ping",
+		Reason:    "engine did not answer",
+		Hint:      "the port is open but the database did not complete a handshake; check TLS requirements and the engine logs",
+		Retryable: true,
+		Err:       err,
+	}
+}
+
+// readinessAttempt runs one DNS + TCP (+ engine ping) attempt.
+func readinessAttempt(ctx context.Context, conn rdsConnection, mode string) *readinessFailure {
+	attemptCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if _, err := net.DefaultResolver.LookupHost(attemptCtx, conn.Host); err != nil {
+		return diagnoseDialError(err)
+	}
+
+	var dialer net.Dialer
+	tcpConn, err := dialer.DialContext(attemptCtx, "tcp", conn.address())
+	if err != nil {
+		return diagnoseDialError(err)
+	}
+	tcpConn.Close()
+
+	if mode != "ping" || sqlFamily(conn.Engine) == "" {
+		return nil
+	}
+
+	db, err := openRDSDatabase(conn)
+	if err != nil {
+		return &readinessFailure{Stage: "ping", Reason: "driver setup failed", Err: err}
+	}
+	defer db.Close()
+
+	pingCtx, pingCancel := context.WithTimeout(ctx, 15*time.Second)
+	defer pingCancel()
+	if err := db.PingContext(pingCtx); err != nil {
+		return diagnosePingError(err)
+	}
+	return nil
+}
+
+// probeRDSReadiness retries readiness attempts until the instance answers,
+// a non-retryable failure occurs or the timeout expires. Every failure is
+// reported as JSONL with its diagnosis.
+func probeRDSReadiness(ctx context.Context, conn rdsConnection, settings readinessSettings) error {
+	mode := strings.ToLower(helpers.WithFallbackValue(settings.Mode, "tcp"))
+	switch mode {
+	case "none":
+		return nil
+	case "tcp", "ping":
+	default:
+		return fmt.Errorf("unsupported readiness_check: %s (expected: none, tcp or ping)", mode)
+	}
+
+	if mode == "ping" && sqlFamily(conn.Engine) == "" {
+		helpers.Info("engine-level ping is not supported for engine %s; falling back to a TCP check", conn.Engine)
+	}
+
+	helpers.Info("checking readiness of %s (check=%s timeout=%s)", conn.address(), mode, settings.Timeout)
+
+	deadline := time.Now().Add(settings.Timeout)
+	for attempt := 1; ; attempt++ {
+		failure := readinessAttempt(ctx, conn, mode)
+		if failure == nil {
+			helpers.Info("%s is ready (attempt %d)", conn.address(), attempt)
+			return nil
+		}
+
+		helpers.Debug("readiness attempt %d for %s: %s: %v", attempt, conn.address(), failure.Reason, failure.Err)
+
+		if !failure.Retryable || time.Now().Add(settings.Interval).After(deadline) {
+			helpers.Error("%s is not ready: %s check failed (%s). %s", conn.address(), failure.Stage, failure.Reason, failure.Hint)
+			return failure
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(settings.Interval):
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+)
+
+var sharedIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// sanitizeSQLIdentifier turns a Compose service name into a safe database / role name.
+func sanitizeSQLIdentifier(v string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(v) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+
+	out := b.String()
+	if out == "" || (out[0] >= '0' && out[0] <= '9') {
+		out = "svc_" + out
+	}
+	if len(out) > 63 {
+		out = out[:63]
+	}
+	return out
+}
+
+// sharedDatabaseNames returns the logical database and app role used by a
+// service on a shared instance.
+func sharedDatabaseNames(opt structs.Options) (string, string, error) {
+	name := helpers.WithFallbackValue(opt.Name, "rds")
+
+	dbName := helpers.WithFallbackValue(opt.DBName, sanitizeSQLIdentifier(name))
+	appUser := helpers.WithFallbackValue(opt.AppUsername, sanitizeSQLIdentifier(name)+"_app")
+
+	if !sharedIdentifierPattern.MatchString(dbName) {
+		return "", "", fmt.Errorf("db_name %q must contain only letters, digits and underscores", dbName)
+	}
+	if !sharedIdentifierPattern.MatchString(appUser) {
+		return "", "", fmt.Errorf("app_username %q must contain only letters, digits and underscores", appUser)
+	}
+	return dbName, appUser, nil
+}
+
+// sharedDownDrops reports whether down_action drops a shared tenant's database
+// and role. Only delete does; stop leaves the tenant alone, as the instance
+// belongs to another service, and snapshot is refused because a snapshot of the
+// shared instance would not be this service's to restore.
+func sharedDownDrops(action string) (bool, error) {
+	switch action {
+	case "stop":
+		return false, nil
+	case "delete":
+		return true, nil
+	case "snapshot":
+		return false, fmt.Errorf("down_action snapshot is not supported with shared_instance; dump the database first and use down_action delete")
+	default:
+		return false, fmt.Errorf("unsupported down_action %q", action)
+	}
+}
+
+// generatePassword returns a random alphanumeric password.
+func generatePassword(length int) (string, error) {
+	const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+	out := make([]byte, length)
+	for i := range out {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		out[i] = alphabet[n.Int64()]
+	}
+	return string(out), nil
+}
+
+// adminDatabase is the database the master user connects to for server-level DDL.
+func adminDatabase(family string) string {
+	switch family {
+	case "postgres":
+		return "postgres"
+	case "sqlserver":
+		return "master"
+	default:
+		return ""
+	}
+}
+
+func quoteIdent(family, v string) string {
+	switch family {
+	case "postgres":
+		return `"` + strings.ReplaceAll(v, `"`, `""`) + `"`
+	case "sqlserver":
+		return "[" + strings.ReplaceAll(v, "]", "]]") + "]"
+	default:
+		return "`" + strings.ReplaceAll(v, "`", "``") + "`"
+	}
+}
+
+func quoteLiteral(family, v string) string {
+	switch family {
+	case "sqlserver":
+		return "N'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case "mysql":
+		v = strings.ReplaceAll(v, `\`, `\\`)
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	default:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	}
+}
+
+// execAll runs the statements in order, stopping at the first failure.
+func execAll(ctx context.Context, db *sql.DB, statements ...string) error {
+	for _, stmt := range statements {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			// Only report the leading keywords; statements may embed passwords.
+			words := strings.Fields(stmt)
+			if len(words) > 2 {
+				words = words[:2]
+			}
+			return fmt.Errorf("%s: %w", strings.Join(words, " "), err)
+		}
+	}
+	return nil
+}
+
+// exists runs a query with a single bind parameter and reports whether it returned a row.
+func exists(ctx context.Context, db *sql.DB, query, arg string) (bool, error) {
+	rows, err := db.QueryContext(ctx, query, arg)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	return rows.Next(), rows.Err()
+}
+
+// openAdminConnection opens and pings a master connection to the given database.
+func openAdminConnection(ctx context.Context, master rdsConnection) (*sql.DB, error) {
+	db, err := openRDSDatabase(master)
+	if err != nil {
+		return nil, err
+	}
+	if err := waitForRDSConnection(ctx, db, 5*time.Minute); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// provisionSharedDatabase connects to the shared instance as master, creates the
+// service's logical database and a dedicated role limited to it, and (re)sets
+// the role's password.
+func provisionSharedDatabase(ctx context.Context, master rdsConnection, dbName, appUser, appPassword string) error {
+	family := sqlFamily(master.Engine)
+	if family == "" {
+		return fmt.Errorf("shared_instance is not supported for engine %s", master.Engine)
+	}
+
+	db, err := openAdminConnection(ctx, master.withDatabase(adminDatabase(family)))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	qdb := quoteIdent(family, dbName)
+	quser := quoteIdent(family, appUser)
+	qpass := quoteLiteral(family, appPassword)
+
+	switch family {
+	case "postgres":
+		roleExists, err := exists(ctx, db, "SELECT 1 FROM pg_roles WHERE rolname = $1", appUser)
+		if err != nil {
+			return err
+		}
+		if roleExists {
+			err = execAll(ctx, db, fmt.Sprintf("ALTER ROLE %s WITH LOGIN PASSWORD %s", quser, qpass))
+		} else {
+			err = execAll(ctx, db, fmt.Sprintf("CREATE ROLE %s WITH LOGIN PASSWORD %s", quser, qpass))
+		}
+		if err != nil {
+			return err
+		}
+
+		dbExists, err := exists(ctx, db, "SELECT 1 FROM pg_database WHERE datname = $1", dbName)
+		if err != nil {
+			return err
+		}
+		if !dbExists {
+			if err := execAll(ctx, db, "CREATE DATABASE "+qdb); err != nil {
+				return err
+			}
+		}
+		err = execAll(ctx, db,
+			fmt.Sprintf("REVOKE ALL ON DATABASE %s FROM PUBLIC", qdb),
+			fmt.Sprintf("GRANT CONNECT, TEMPORARY ON DATABASE %s TO %s", qdb, quser),
+		)
+		if err != nil {
+			return err
+		}
+
+		// Schema-level grants have to be issued from inside the new database.
+		appDB, err := openAdminConnection(ctx, master.withDatabase(dbName))
+		if err != nil {
+			return err
+		}
+		defer appDB.Close()
+
+		return execAll(ctx, appDB,
+			"REVOKE CREATE ON SCHEMA public FROM PUBLIC",
+			fmt.Sprintf("GRANT USAGE, CREATE ON SCHEMA public TO %s", quser),
+			fmt.Sprintf("ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO %s", quser),
+			fmt.Sprintf("ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO %s", quser),
+		)
+
+	case "mysql":
+		return execAll(ctx, db,
+			"CREATE DATABASE IF NOT EXISTS "+qdb,
+			fmt.Sprintf("CREATE USER IF NOT EXISTS %s@'%%' IDENTIFIED BY %s", quoteLiteral(family, appUser), qpass),
+			fmt.Sprintf("ALTER USER %s@'%%' IDENTIFIED BY %s", quoteLiteral(family, appUser), qpass),
+			fmt.Sprintf(
+				"GRANT SELECT, INSERT, UPDATE, DELETE, CREATE, ALTER, DROP, INDEX, REFERENCES, "+
+					"CREATE TEMPORARY TABLES, LOCK TABLES, EXECUTE, CREATE VIEW, SHOW VIEW, TRIGGER ON %s.* TO %s@'%%'",
+				qdb, quoteLiteral(family, appUser),
+			),
+		)
+
+	default: // sqlserver
+		err := execAll(ctx, db,
+			fmt.Sprintf("IF DB_ID(%s) IS NULL CREATE DATABASE %s", quoteLiteral(family, dbName), qdb),
+			fmt.Sprintf(
+				"IF SUSER_ID(%s) IS NULL CREATE LOGIN %s WITH PASSWORD = %s ELSE ALTER LOGIN %s WITH PASSWORD = %s",
+				quoteLiteral(family, appUser), quser, qpass, quser, qpass,
+			),
+		)
+		if err != nil {
+			return err
+		}
+
+		appDB, err := openAdminConnection(ctx, master.withDatabase(dbName))
+		if err != nil {
+			return err
+		}
+		defer appDB.Close()
+
+		return execAll(ctx, appDB,
+			fmt.Sprintf("IF USER_ID(%s) IS NULL CREATE USER %s FOR LOGIN %s", quoteLiteral(family, appUser), quser, quser),
+			fmt.Sprintf("ALTER ROLE db_datareader ADD MEMBER %s", quser),
+			fmt.Sprintf("ALTER ROLE db_datawriter ADD MEMBER %s", quser),
+			fmt.Sprintf("ALTER ROLE db_ddladmin ADD MEMBER %s", quser),
+		)
+	}
+}
+
+// dropSharedDatabase removes the service's logical database and app role from
+// the shared instance, leaving the instance itself untouched.
+func dropSharedDatabase(ctx context.Context, master rdsConnection, dbName, appUser string) error {
+	family := sqlFamily(master.Engine)
+	if family == "" {
+		return fmt.Errorf("shared_instance is not supported for engine %s", master.Engine)
+	}
+
+	db, err := openAdminConnection(ctx, master.withDatabase(adminDatabase(family)))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	qdb := quoteIdent(family, dbName)
+	quser := quoteIdent(family, appUser)
+
+	switch family {
+	case "postgres":
+		if _, err := db.ExecContext(ctx, "SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = $1 AND pid <> pg_backend_pid()", dbName); err != nil {
+			return err
+		}
+		return execAll(ctx, db,
+			"DROP DATABASE IF EXISTS "+qdb,
+			"DROP ROLE IF EXISTS "+quser,
+		)
+
+	case "mysql":
+		return execAll(ctx, db,
+			"DROP DATABASE IF EXISTS "+qdb,
+			fmt.Sprintf("DROP USER IF EXISTS %s@'%%'", quoteLiteral(family, appUser)),
+		)
+
+	default: // sqlserver
+		return execAll(ctx, db,
+			fmt.Sprintf(
+				"IF DB_ID(%s) IS NOT NULL BEGIN ALTER DATABASE %s SET SINGLE_USER WITH ROLLBACK IMMEDIATE; DROP DATABASE %s; END",
+				quoteLiteral(family, dbName), qdb, qdb,
+			),
+			fmt.Sprintf("IF SUSER_ID(%s) IS NOT NULL DROP LOGIN %s", quoteLiteral(family, appUser), quser),
+		)
+	}
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSharedDownDrops(t *testing.T) {
+	tests := []struct {
+		action   string
+		wantDrop bool
+		wantErr  string
+	}{
+		{action: "delete", wantDrop: true},
+		{action: "stop"},
+		{action: "snapshot", wantErr: "not supported with shared_instance"},
+		{action: "destroy", wantErr: "unsupported down_action"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			drop, err := sharedDownDrops(tt.action)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				if drop {
+					t.Errorf("drop = true alongside an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if drop != tt.wantDrop {
+				t.Errorf("drop = %v, want %v", drop, tt.wantDrop)
+			}
+		})
+	}
+}
@@ -0,0 +1,479 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// rdsOwnershipTags returns the ownership tags for RDS resources of the service.
+func rdsOwnershipTags(opt structs.Options) []rdstypes.Tag {
+	values := ownershipTagValues(opt, "rds")
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tags := make([]rdstypes.Tag, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, rdstypes.Tag{Key: aws.String(k), Value: aws.String(values[k])})
+	}
+	return tags
+}
+
+// rdsTagMap flattens an RDS tag list.
+func rdsTagMap(tags []rdstypes.Tag) map[string]string {
+	out := make(map[string]string, len(tags))
+	for _, t := range tags {
+		out[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	return out
+}
+
+// ownedRDSSnapshots lists the manual snapshots of the instance that carry the
+// service's ownership tags, newest first.
+func ownedRDSSnapshots(ctx context.Context, client *rds.Client, opt structs.Options, name string) ([]rdstypes.DBSnapshot, error) {
+	want := ownershipTagValues(opt, "rds")
+
+	var snapshots []rdstypes.DBSnapshot
+	paginator := rds.NewDescribeDBSnapshotsPaginator(client, &rds.DescribeDBSnapshotsInput{
+		DBInstanceIdentifier: aws.String(name),
+		SnapshotType:         aws.String("manual"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range page.DBSnapshots {
+			if ownedBy(rdsTagMap(s.TagList), want) {
+				snapshots = append(snapshots, s)
+			}
+		}
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return aws.ToTime(snapshots[i].SnapshotCreateTime).After(aws.ToTime(snapshots[j].SnapshotCreateTime))
+	})
+	return snapshots, nil
+}
+
+// ownedRDSSnapshot returns a single snapshot, refusing snapshots that do not
+// belong to the service.
+func ownedRDSSnapshot(ctx context.Context, client *rds.Client, opt structs.Options, snapshotID string) (*rdstypes.DBSnapshot, error) {
+	out, err := client.DescribeDBSnapshots(ctx, &rds.DescribeDBSnapshotsInput{
+		DBSnapshotIdentifier: aws.String(snapshotID),
+	})
+	if err != nil {
+		var notFound *rdstypes.DBSnapshotNotFoundFault
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("snapshot %s does not exist", snapshotID)
+		}
+		return nil, err
+	}
+	if len(out.DBSnapshots) == 0 {
+		return nil, fmt.Errorf("snapshot %s does not exist", snapshotID)
+	}
+
+	snapshot := &out.DBSnapshots[0]
+	if !ownedBy(rdsTagMap(snapshot.TagList), ownershipTagValues(opt, "rds")) {
+		return nil, fmt.Errorf("snapshot %s is not tagged as belonging to this project / service", snapshotID)
+	}
+	return snapshot, nil
+}
+
+// RDSSnapshotCreate takes a tagged manual snapshot of the service's instance.
+func RDSSnapshotCreate(ctx context.Context, opt structs.Options) error {
+	region := helpers.WithFallbackValue(opt.Region, "ap-southeast-1")
+	name := helpers.WithFallbackValue(opt.Name, "rds")
+	snapshotID := helpers.WithFallbackValue(opt.SnapshotID, fmt.Sprintf("%s-%s", name, time.Now().UTC().Format("20060102-150405")))
+
+	cfg, err := helpers.LoadAWSConfig(ctx, region)
+	if err != nil {
+		helpers.Error("unable to load AWS config: %v", err)
+		return err
+	}
+
+	client := rds.NewFromConfig(cfg)
+
+	helpers.Info("creating snapshot %s of RDS instance %s", snapshotID, name)
+
+	_, err = client.CreateDBSnapshot(ctx, &rds.CreateDBSnapshotInput{
+		DBInstanceIdentifier: aws.String(name),
+		DBSnapshotIdentifier: aws.String(snapshotID),
+		Tags:                 rdsOwnershipTags(opt),
+	})
+	if err != nil {
+		helpers.Error("create DB snapshot failed: %v", err)
+		return err
+	}
+
+	waiter := rds.NewDBSnapshotAvailableWaiter(client)
+	waitErr := waiter.Wait(ctx, &rds.DescribeDBSnapshotsInput{
+		DBSnapshotIdentifier: aws.String(snapshotID),
+	}, 60*time.Minute)
+	if waitErr != nil {
+		helpers.Error("waiting for DB snapshot to become available failed: %v", waitErr)
+		return waitErr
+	}
+
+	helpers.Info("snapshot %s of %s is available", snapshotID, name)
+	return nil
+}
+
+// RDSSnapshotList prints the service's snapshots, newest first.
+func RDSSnapshotList(ctx context.Context, opt structs.Options) error {
+	region := helpers.WithFallbackValue(opt.Region, "ap-southeast-1")
+	name := helpers.WithFallbackValue(opt.Name, "rds")
+
+	cfg, err := helpers.LoadAWSConfig(ctx, region)
+	if err != nil {
+		helpers.Error("unable to load AWS config: %v", err)
+		return err
+	}
+
+	snapshots, err := ownedRDSSnapshots(ctx, rds.NewFromConfig(cfg), opt, name)
+	if err != nil {
+		helpers.Error("describe DB snapshots failed: %v", err)
+		return err
+	}
+
+	if len(snapshots) == 0 {
+		helpers.Info("no snapshots of %s found", name)
+		return nil
+	}
+
+	for _, s := range snapshots {
+		helpers.Info(
+			"snapshot %s (status=%s created=%s engine=%s %s storage=%dGiB)",
+			aws.ToString(s.DBSnapshotIdentifier),
+			aws.ToString(s.Status),
+			aws.ToTime(s.SnapshotCreateTime).UTC().Format(time.RFC3339),
+			aws.ToString(s.Engine),
+			aws.ToString(s.EngineVersion),
+			aws.ToInt32(s.AllocatedStorage),
+		)
+	}
+	return nil
+}
+
+// RDSSnapshotDelete deletes one of the service's snapshots.
+func RDSSnapshotDelete(ctx context.Context, opt structs.Options) error {
+	region := helpers.WithFallbackValue(opt.Region, "ap-southeast-1")
+
+	if opt.SnapshotID == "" {
+		helpers.Error("snapshot delete needs --snapshot_id")
+		return fmt.Errorf("no snapshot_id specified")
+	}
+
+	cfg, err := helpers.LoadAWSConfig(ctx, region)
+	if err != nil {
+		helpers.Error("unable to load AWS config: %v", err)
+		return err
+	}
+
+	client := rds.NewFromConfig(cfg)
+
+	if _, err := ownedRDSSnapshot(ctx, client, opt, opt.SnapshotID); err != nil {
+		helpers.Error("%v", err)
+		return err
+	}
+
+	helpers.Info("deleting snapshot %s", opt.SnapshotID)
+
+	_, err = client.DeleteDBSnapshot(ctx, &rds.DeleteDBSnapshotInput{
+		DBSnapshotIdentifier: aws.String(opt.SnapshotID),
+	})
+	if err != nil {
+		helpers.Error("delete DB snapshot failed: %v", err)
+		return err
+	}
+
+	helpers.Info("snapshot %s deleted", opt.SnapshotID)
+	return nil
+}
+
+// waitForRenamedInstance waits until an instance is reachable (and available)
+// under its new identifier.
+func waitForRenamedInstance(ctx context.Context, client *rds.Client, name string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		instance, err := findRDSInstance(ctx, client, name)
+		if err != nil {
+			return err
+		}
+		if instance != nil && aws.ToString(instance.DBInstanceStatus) == "available" {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for DB instance %s to be renamed", name)
+		case <-ticker.C:
+		}
+	}
+}
+
+// preRestorePrefix is the identifier prefix of instances renamed out of the way
+// by a restore.
+func preRestorePrefix(name string) string {
+	return name + "-pre-restore-"
+}
+
+// copyRDSInstanceShape makes the restored instance match the one it replaces:
+// sizing, storage, networking, parameter and option groups, and log exports.
+func copyRDSInstanceShape(input *rds.RestoreDBInstanceFromDBSnapshotInput, current *rdstypes.DBInstance) {
+	input.DBInstanceClass = current.DBInstanceClass
+	input.MultiAZ = current.MultiAZ
+	input.PubliclyAccessible = current.PubliclyAccessible
+	input.CopyTagsToSnapshot = current.CopyTagsToSnapshot
+	input.DeletionProtection = current.DeletionProtection
+	input.AutoMinorVersionUpgrade = current.AutoMinorVersionUpgrade
+	input.CACertificateIdentifier = current.CACertificateIdentifier
+	input.EnableCloudwatchLogsExports = current.EnabledCloudwatchLogsExports
+
+	if current.DBSubnetGroup != nil {
+		input.DBSubnetGroupName = current.DBSubnetGroup.DBSubnetGroupName
+	}
+	for _, sg := range current.VpcSecurityGroups {
+		input.VpcSecurityGroupIds = append(input.VpcSecurityGroupIds, aws.ToString(sg.VpcSecurityGroupId))
+	}
+	if len(current.DBParameterGroups) > 0 {
+		input.DBParameterGroupName = current.DBParameterGroups[0].DBParameterGroupName
+	}
+	if len(current.OptionGroupMemberships) > 0 {
+		input.OptionGroupName = current.OptionGroupMemberships[0].OptionGroupName
+	}
+
+	input.StorageType = current.StorageType
+	switch aws.ToString(current.StorageType) {
+	case "io1", "io2":
+		input.Iops = current.Iops
+	case "gp3":
+		// Below the engine's threshold gp3 runs at its baseline, and RDS
+		// rejects explicit IOPS / throughput
+		threshold := int32(400)
+		switch engineSpecFor(aws.ToString(current.Engine)).Family {
+		case "sqlserver":
+			threshold = 20
+		case "oracle":
+			threshold = 200
+		}
+		if aws.ToInt32(current.AllocatedStorage) >= threshold {
+			input.Iops = current.Iops
+			input.StorageThroughput = current.StorageThroughput
+		}
+	}
+}
+
+// copyRDSInstanceMonitoring turns on the Enhanced Monitoring and Performance
+// Insights settings of the replaced instance, which a restore cannot set.
+func copyRDSInstanceMonitoring(ctx context.Context, client *rds.Client, name string, current *rdstypes.DBInstance) error {
+	modify := &rds.ModifyDBInstanceInput{
+		DBInstanceIdentifier: aws.String(name),
+		ApplyImmediately:     aws.Bool(true),
+	}
+	changed := false
+
+	if aws.ToInt32(current.MonitoringInterval) > 0 {
+		modify.MonitoringInterval = current.MonitoringInterval
+		modify.MonitoringRoleArn = current.MonitoringRoleArn
+		changed = true
+	}
+	if aws.ToBool(current.PerformanceInsightsEnabled) {
+		modify.EnablePerformanceInsights = aws.Bool(true)
+		modify.PerformanceInsightsRetentionPeriod = current.PerformanceInsightsRetentionPeriod
+		modify.PerformanceInsightsKMSKeyId = current.PerformanceInsightsKMSKeyId
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	helpers.Info("applying the monitoring settings of the previous instance to %s", name)
+
+	if _, err := client.ModifyDBInstance(ctx, modify); err != nil {
+		helpers.Error("modify DB instance failed: %v", err)
+		return err
+	}
+	if err := waitForRDSModification(ctx, client, name, "available", 30*time.Minute); err != nil {
+		helpers.Error("waiting for DB instance modification failed: %v", err)
+		return err
+	}
+	return nil
+}
+
+// deletePreRestoreInstances deletes the instances a restore renamed out of the
+// way and kept (keep_previous, or a failed restore). Only instances with the
+// service's ownership tags are touched.
+func deletePreRestoreInstances(ctx context.Context, client *rds.Client, opt structs.Options, name, region string) error {
+	want := ownershipTagValues(opt, "rds")
+
+	paginator := rds.NewDescribeDBInstancesPaginator(client, &rds.DescribeDBInstancesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			helpers.Error("describe DB instances failed: %v", err)
+			return err
+		}
+
+		for _, instance := range page.DBInstances {
+			id := aws.ToString(instance.DBInstanceIdentifier)
+			if !strings.HasPrefix(id, preRestorePrefix(name)) || !ownedBy(rdsTagMap(instance.TagList), want) {
+				continue
+			}
+			if aws.ToString(instance.DBInstanceStatus) == "deleting" {
+				continue
+			}
+
+			if err := releaseDeletionProtection(ctx, client, id, opt.Force); err != nil {
+				return err
+			}
+			if err := deleteRDSInstance(ctx, client, id, region, false, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RDSSnapshotRestore rolls the service's instance back to a snapshot. The
+// current instance is renamed out of the way, the snapshot is restored under
+// the original identifier (so the endpoint is unchanged) with the same
+// networking and sizing, and the previous instance is deleted unless
+// keep_previous is set.
+func RDSSnapshotRestore(ctx context.Context, opt structs.Options) error {
+	region := helpers.WithFallbackValue(opt.Region, "ap-southeast-1")
+	name := helpers.WithFallbackValue(opt.Name, "rds")
+
+	if opt.SnapshotID == "" {
+		helpers.Error("snapshot restore needs --snapshot_id")
+		return fmt.Errorf("no snapshot_id specified")
+	}
+
+	cfg, err := helpers.LoadAWSConfig(ctx, region)
+	if err != nil {
+		helpers.Error("unable to load AWS config: %v", err)
+		return err
+	}
+
+	client := rds.NewFromConfig(cfg)
+
+	snapshot, err := ownedRDSSnapshot(ctx, client, opt, opt.SnapshotID)
+	if err != nil {
+		helpers.Error("%v", err)
+		return err
+	}
+
+	current, err := findRDSInstance(ctx, client, name)
+	if err != nil {
+		helpers.Error("describe DB instances failed: %v", err)
+		return err
+	}
+
+	restoreInput := &rds.RestoreDBInstanceFromDBSnapshotInput{
+		DBInstanceIdentifier: aws.String(name),
+		DBSnapshotIdentifier: snapshot.DBSnapshotIdentifier,
+		Tags:                 rdsOwnershipTags(opt),
+	}
+
+	previous := ""
+	if current != nil {
+		current, err = ensureRDSInstanceReady(ctx, client, current)
+		if err != nil {
+			return err
+		}
+
+		copyRDSInstanceShape(restoreInput, current)
+
+		previous = fmt.Sprintf("%s%s", preRestorePrefix(name), time.Now().UTC().Format("20060102150405"))
+		helpers.Info("renaming RDS instance %s to %s before restoring %s", name, previous, opt.SnapshotID)
+
+		_, err = client.ModifyDBInstance(ctx, &rds.ModifyDBInstanceInput{
+			DBInstanceIdentifier:    aws.String(name),
+			NewDBInstanceIdentifier: aws.String(previous),
+			ApplyImmediately:        aws.Bool(true),
+		})
+		if err != nil {
+			helpers.Error("rename DB instance failed: %v", err)
+			return err
+		}
+
+		if err := waitForRenamedInstance(ctx, client, previous, 30*time.Minute); err != nil {
+			helpers.Error("waiting for rename failed: %v", err)
+			return err
+		}
+
+		// Tag it as the service's, so down cleans it up if it is kept or the
+		// restore fails
+		renamed, err := describeRDSInstance(ctx, client, previous)
+		if err != nil {
+			helpers.Error("describe DB instance failed: %v", err)
+			return err
+		}
+		_, err = client.AddTagsToResource(ctx, &rds.AddTagsToResourceInput{
+			ResourceName: renamed.DBInstanceArn,
+			Tags:         rdsOwnershipTags(opt),
+		})
+		if err != nil {
+			helpers.Error("tag DB instance %s failed: %v", previous, err)
+			return err
+		}
+	}
+
+	helpers.Info("restoring snapshot %s as RDS instance %s", opt.SnapshotID, name)
+
+	_, err = client.RestoreDBInstanceFromDBSnapshot(ctx, restoreInput)
+	if err != nil {
+		helpers.Error("restore DB snapshot failed: %v", err)
+		if previous != "" {
+			helpers.Error("the previous instance is still available as %s; rename it back to %s to recover", previous, name)
+		}
+		return err
+	}
+
+	waiter := rds.NewDBInstanceAvailableWaiter(client)
+	waitErr := waiter.Wait(ctx, &rds.DescribeDBInstancesInput{
+		DBInstanceIdentifier: aws.String(name),
+	}, 60*time.Minute)
+	if waitErr != nil {
+		helpers.Error("waiting for restored DB instance failed: %v", waitErr)
+		return waitErr
+	}
+
+	helpers.Info("RDS instance %s restored from %s", name, opt.SnapshotID)
+
+	if previous == "" {
+		return nil
+	}
+
+	if err := copyRDSInstanceMonitoring(ctx, client, name, current); err != nil {
+		return err
+	}
+	if opt.KeepPrevious {
+		helpers.Info("previous instance kept as %s", previous)
+		return nil
+	}
+
+	// The previous instance was replaced on purpose, so its protection goes too
+	if err := releaseDeletionProtection(ctx, client, previous, true); err != nil {
+		return err
+	}
+	return deleteRDSInstance(ctx, client, previous, region, false, nil)
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/microsoft/go-mssqldb"
+)
+
+// rdsConnection holds everything needed to open a SQL connection to an instance.
+type rdsConnection struct {
+	Engine   string
+	Host     string
+	Port     int
+	Username string
+	Password string
+	DBName   string
+	TLS      *tls.Config // verifies the instance certificate when set
+}
+
+// sqlFamily maps an RDS engine onto the SQL dialect / Go driver we use for it,
+// or "" for engines without a bundled driver (Oracle, Db2).
+func sqlFamily(engine string) string {
+	switch family := engineSpecFor(engine).Family; family {
+	case "postgres", "mysql", "sqlserver":
+		return family
+	default:
+		return ""
+	}
+}
+
+// withDatabase returns a copy of the connection pointing at another database.
+func (c rdsConnection) withDatabase(dbName string) rdsConnection {
+	c.DBName = dbName
+	return c
+}
+
+// address returns the host:port pair of the connection.
+func (c rdsConnection) address() string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+}
+
+// driverDSN builds the driver name and DSN used by database/sql.
+func (c rdsConnection) driverDSN() (string, string, error) {
+	switch sqlFamily(c.Engine) {
+	case "postgres":
+		u := url.URL{
+			Scheme:   "postgres",
+			User:     url.UserPassword(c.Username, c.Password),
+			Host:     c.address(),
+			Path:     "/" + c.DBName,
+			RawQuery: "connect_timeout=10",
+		}
+		return "pgx", u.String(), nil
+
+	case "mysql":
+		cfg := mysql.NewConfig()
+		cfg.User = c.Username
+		cfg.Passwd = c.Password
+		cfg.Net = "tcp"
+		cfg.Addr = c.address()
+		cfg.DBName = c.DBName
+		cfg.MultiStatements = true
+		cfg.Timeout = 10 * time.Second
+		return "mysql", cfg.FormatDSN(), nil
+
+	case "sqlserver":
+		query := url.Values{}
+		query.Set("dial timeout", "10")
+		if c.DBName != "" {
+			query.Set("database", c.DBName)
+		}
+		u := url.URL{
+			Scheme:   "sqlserver",
+			User:     url.UserPassword(c.Username, c.Password),
+			Host:     c.address(),
+			RawQuery: query.Encode(),
+		}
+		return "sqlserver", u.String(), nil
+
+	default:
+		return "", "", fmt.Errorf("SQL connections are not supported for engine %s", c.Engine)
+	}
+}
+
+// openRDSDatabase opens (but does not ping) a database/sql handle for the connection.
+func openRDSDatabase(c rdsConnection) (*sql.DB, error) {
+	driver, dsn, err := c.driverDSN()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.TLS == nil {
+		db, err := sql.Open(driver, dsn)
+		if err != nil {
+			return nil, fmt.Errorf("open %s connection to %s: %w", driver, c.address(), err)
+		}
+		db.SetMaxOpenConns(2)
+		return db, nil
+	}
+
+	connector, err := c.tlsConnector(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open %s TLS connection to %s: %w", driver, c.address(), err)
+	}
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(2)
+	return db, nil
+}
+
+// waitForRDSConnection pings the database until it answers or the timeout expires.
+func waitForRDSConnection(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var lastErr error
+	for {
+		pingCtx, pingCancel := context.WithTimeout(ctx, 15*time.Second)
+		lastErr = db.PingContext(pingCtx)
+		pingCancel()
+		if lastErr == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database not reachable after %s: %w", timeout, lastErr)
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// placeholder returns the n-th (1-based) bind parameter for the SQL family.
+func placeholder(family string, n int) string {
+	switch family {
+	case "postgres":
+		return fmt.Sprintf("$%d", n)
+	case "sqlserver":
+		return fmt.Sprintf("@p%d", n)
+	default:
+		return "?"
+	}
+}
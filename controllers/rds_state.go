@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// rdsStateAction is what RDSUp has to do with an instance in a given status.
+type rdsStateAction int
+
+const (
+	rdsStateReady rdsStateAction = iota
+	rdsStateWait
+	rdsStateWaitStopped
+	rdsStateStart
+	rdsStateRefuse
+)
+
+// rdsTransitionalStates are statuses that eventually settle into "available"
+// on their own, so the only sensible thing to do is to wait.
+var rdsTransitionalStates = map[string]bool{
+	"backing-up":                                      true,
+	"configuring-activity-stream":                     true,
+	"configuring-enhanced-monitoring":                 true,
+	"configuring-iam-database-auth":                   true,
+	"configuring-log-exports":                         true,
+	"converting-to-vpc":                               true,
+	"creating":                                        true,
+	"delete-precheck":                                 true,
+	"inaccessible-encryption-credentials-recoverable": true,
+	"maintenance":                                     true,
+	"modifying":                                       true,
+	"moving-to-vpc":                                   true,
+	"rebooting":                                       true,
+	"renaming":                                        true,
+	"resetting-master-credentials":                    true,
+	"starting":                                        true,
+	"storage-config-upgrade":                          true,
+	"storage-initializing":                            true,
+	"storage-optimization":                            true,
+	"upgrading":                                       true,
+}
+
+// rdsRefusedStates maps statuses we cannot recover from automatically to an
+// actionable hint for the user.
+var rdsRefusedStates = map[string]string{
+	"deleting":                            "the instance is being deleted; wait for the deletion to finish and run up again, or choose a different name",
+	"failed":                              "the instance has failed; inspect it in the RDS console, delete it and run up again",
+	"incompatible-network":                "the instance's subnet group or VPC is no longer usable; fix the networking or delete the instance and run up again",
+	"incompatible-option-group":           "the option group is incompatible; attach a valid option group in the RDS console and run up again",
+	"incompatible-parameters":             "the parameter group is incompatible; fix or reset the parameter group and run up again",
+	"incompatible-restore":                "a point-in-time restore failed; delete the instance and run up again",
+	"incompatible-credentials":            "the instance credentials are invalid; reset the master password and run up again",
+	"inaccessible-encryption-credentials": "the KMS key used by the instance is disabled or deleted; re-enable the key and run up again",
+	"insufficient-capacity":               "there is not enough capacity for this instance class; choose a different instance_class or try again later",
+	"restore-error":                       "restoring the instance failed; delete the instance and run up again",
+	"storage-full":                        "the instance is out of storage; raise allocated_storage and run up with reconcile=true and apply_immediately=true, or grow it in the RDS console",
+}
+
+// growsFullStorage reports whether a storage-full instance can be recovered by
+// reconciling it: reconcile and apply_immediately are on and allocated_storage
+// asks for more storage than the instance has.
+func growsFullStorage(instance *rdstypes.DBInstance, opt structs.Options) bool {
+	return aws.ToString(instance.DBInstanceStatus) == "storage-full" &&
+		opt.Reconcile &&
+		opt.ApplyImmediately &&
+		allocatedStorageOrDefault(opt.AllocatedStorage) > aws.ToInt32(instance.AllocatedStorage)
+}
+
+// classifyRDSState decides what to do with an instance in the given status.
+func classifyRDSState(status string) rdsStateAction {
+	switch {
+	case status == "available":
+		return rdsStateReady
+	case status == "stopped":
+		return rdsStateStart
+	case status == "stopping":
+		return rdsStateWaitStopped
+	case rdsTransitionalStates[status]:
+		return rdsStateWait
+	default:
+		return rdsStateRefuse
+	}
+}
+
+// findRDSInstance describes the instance, returning nil (and no error) when it
+// does not exist. Any other describe failure is returned to the caller.
+func findRDSInstance(ctx context.Context, client *rds.Client, name string) (*rdstypes.DBInstance, error) {
+	instance, err := describeRDSInstance(ctx, client, name)
+	if err != nil {
+		var notFound *rdstypes.DBInstanceNotFoundFault
+		if errors.As(err, &notFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return instance, nil
+}
+
+// ensureRDSInstanceReady drives an existing instance to "available": it waits
+// for transitional states, starts stopped instances and refuses states that
+// need a human. It returns the fresh description of the available instance.
+func ensureRDSInstanceReady(ctx context.Context, client *rds.Client, instance *rdstypes.DBInstance) (*rdstypes.DBInstance, error) {
+	name := aws.ToString(instance.DBInstanceIdentifier)
+
+	for {
+		status := aws.ToString(instance.DBInstanceStatus)
+
+		switch classifyRDSState(status) {
+		case rdsStateReady:
+			return instance, nil
+
+		case rdsStateStart:
+			return startRDSInstance(ctx, client, name)
+
+		case rdsStateWaitStopped:
+			helpers.Info("RDS instance %s is stopping; waiting for it to stop before starting it again", name)
+			if err := waitForRDSStatus(ctx, client, name, "stopped", 30*time.Minute); err != nil {
+				helpers.Error("waiting for DB instance to stop failed: %v", err)
+				return nil, err
+			}
+
+		case rdsStateWait:
+			helpers.Info("RDS instance %s is %s; waiting for it to become available", name, status)
+			if err := waitForRDSStatus(ctx, client, name, "available", 60*time.Minute); err != nil {
+				helpers.Error("waiting for DB instance to become available failed: %v", err)
+				return nil, err
+			}
+
+		default:
+			hint, ok := rdsRefusedStates[status]
+			if !ok {
+				hint = "this status is not handled automatically; check the instance in the RDS console"
+			}
+			helpers.Error("RDS instance %s is %s: %s", name, status, hint)
+			return nil, fmt.Errorf("db instance %s is %s", name, status)
+		}
+
+		refreshed, err := describeRDSInstance(ctx, client, name)
+		if err != nil {
+			helpers.Error("describe DB instance failed: %v", err)
+			return nil, err
+		}
+		instance = refreshed
+	}
+}
@@ -0,0 +1,198 @@
+package controllers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql/driver"
+	"embed"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// rdsCABundles holds the RDS CA bundles committed under certs:
+// global-bundle.pem and, optionally, <region>-bundle.pem files. They are
+// embedded so that certificate verification never depends on a download, and
+// naming the global bundle makes a build without it fail.
+//
+//go:embed certs/global-bundle.pem certs/*.pem
+var rdsCABundles embed.FS
+
+// rdsTLS is the TLS material used for a service's connections.
+type rdsTLS struct {
+	Bundle []byte
+	Path   string      // where the bundle was written, empty when exported as content
+	Config *tls.Config // set when require_tls is on
+}
+
+// loadRDSCABundle returns the embedded CA bundle for the region: the regional
+// bundle when there is one, otherwise the global bundle.
+func loadRDSCABundle(region string) []byte {
+	name := region + "-bundle.pem"
+	bundle, err := rdsCABundles.ReadFile("certs/" + name)
+	if err != nil {
+		name = "global-bundle.pem"
+		bundle, _ = rdsCABundles.ReadFile("certs/" + name)
+	}
+	helpers.Debug("using embedded RDS CA bundle %s", name)
+	return bundle
+}
+
+// prepareRDSTLS loads the CA bundle, writes it to ca_bundle_path when set and
+// builds the TLS config that verifies the instance as serverName. It returns
+// nil when neither require_tls nor ca_bundle_path is set.
+func prepareRDSTLS(ctx context.Context, opt structs.Options, region, serverName string) (*rdsTLS, error) {
+	if !opt.RequireTLS && opt.CABundlePath == "" {
+		return nil, nil
+	}
+
+	bundle := loadRDSCABundle(region)
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("RDS CA bundle for %s contains no certificates", region)
+	}
+
+	result := &rdsTLS{Bundle: bundle}
+
+	if opt.CABundlePath != "" {
+		path := expandHome(opt.CABundlePath)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, fmt.Errorf("create CA bundle directory: %w", err)
+		}
+		if err := os.WriteFile(path, bundle, 0o644); err != nil {
+			return nil, fmt.Errorf("write CA bundle: %w", err)
+		}
+		helpers.Info("wrote RDS CA bundle to %s", path)
+		result.Path = path
+	}
+
+	if opt.RequireTLS {
+		result.Config = &tls.Config{
+			RootCAs:    pool,
+			ServerName: serverName,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	return result, nil
+}
+
+// exportRDSTLSEnv exports the CA bundle as a path, or as PEM content when it was
+// not written to disk.
+func exportRDSTLSEnv(t *rdsTLS) {
+	if t == nil {
+		return
+	}
+	if t.Path != "" {
+		helpers.Setenv("DB_SSL_ROOT_CERT", t.Path)
+		return
+	}
+	helpers.Setenv("DB_SSL_ROOT_CERT", string(t.Bundle))
+}
+
+// rootCertPath returns where the CA bundle was written, if anywhere.
+func rootCertPath(t *rdsTLS) string {
+	if t == nil {
+		return ""
+	}
+	return t.Path
+}
+
+// rdsSSLMode returns the engine's name for full verification, or for CA-only
+// verification when the DSN host (a tunnel) does not match the certificate.
+func rdsSSLMode(engine string, tunnelled bool) string {
+	switch engineSpecFor(engine).Family {
+	case "postgres":
+		if tunnelled {
+			return "verify-ca"
+		}
+		return "verify-full"
+	case "mysql":
+		if tunnelled {
+			return "VERIFY_CA"
+		}
+		return "VERIFY_IDENTITY"
+	default:
+		return "require"
+	}
+}
+
+// dsnTLSQuery returns the DSN parameters that make the exported DSN verify the
+// instance's certificate. serverName is the instance endpoint host. It returns
+// nil for engines whose DSN has no such parameters.
+func dsnTLSQuery(engine, serverName, rootCertPath string, tunnelled bool) url.Values {
+	query := url.Values{}
+
+	switch engineSpecFor(engine).Family {
+	case "postgres":
+		query.Set("sslmode", rdsSSLMode(engine, tunnelled))
+		if rootCertPath != "" {
+			query.Set("sslrootcert", rootCertPath)
+		}
+	case "mysql":
+		query.Set("ssl-mode", rdsSSLMode(engine, tunnelled))
+		if rootCertPath != "" {
+			query.Set("ssl-ca", rootCertPath)
+		}
+	case "sqlserver":
+		query.Set("encrypt", "true")
+		query.Set("TrustServerCertificate", "false")
+		query.Set("hostNameInCertificate", serverName)
+		if rootCertPath != "" {
+			query.Set("certificate", rootCertPath)
+		}
+	case "db2":
+		if rootCertPath != "" {
+			query.Set("SSLServerCertificate", rootCertPath)
+		}
+	default:
+		return nil
+	}
+
+	return query
+}
+
+// tlsConnector wraps the connection's DSN in a driver connector that uses c.TLS.
+func (c rdsConnection) tlsConnector(driverName, dsn string) (driver.Connector, error) {
+	switch driverName {
+	case "pgx":
+		cfg, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLSConfig = c.TLS
+		cfg.Fallbacks = nil
+		return stdlib.GetConnector(*cfg), nil
+
+	case "mysql":
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		cfg.TLS = c.TLS
+		return mysql.NewConnector(cfg)
+
+	case "sqlserver":
+		cfg, err := msdsn.Parse(dsn)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Encryption = msdsn.EncryptionRequired
+		cfg.TLSConfig = c.TLS
+		return mssql.NewConnectorConfig(cfg), nil
+
+	default:
+		return nil, fmt.Errorf("TLS connections are not supported for driver %s", driverName)
+	}
+}
@@ -0,0 +1,459 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// tunnelEndpoint describes where the local side of an SSH tunnel listens and
+// which host:port containers should use to reach it.
+type tunnelEndpoint struct {
+	Listen     string // address the helper binds, e.g. 127.0.0.1:5432
+	Connect    string // address this process uses to reach the listener
+	ExportHost string // host exported to containers, e.g. host.docker.internal
+	ExportPort int
+}
+
+// tunnelStatePath returns the pid / log file path for a service's tunnel helper.
+func tunnelStatePath(opt structs.Options, ext string) string {
+	project := helpers.WithFallbackValue(opt.Project, "compose")
+	name := helpers.WithFallbackValue(opt.Name, "rds")
+	return filepath.Join(os.TempDir(), "aws-compose-service", fmt.Sprintf("%s-%s-tunnel.%s", project, name, ext))
+}
+
+// expandHome resolves a leading ~ in a path.
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(path, "~"))
+		}
+	}
+	return path
+}
+
+// dockerBridgeAddress returns the IPv4 address of the default docker0 bridge,
+// which host.docker.internal resolves to on Linux with host-gateway.
+func dockerBridgeAddress() string {
+	iface, err := net.InterfaceByName("docker0")
+	if err != nil {
+		return ""
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return ""
+	}
+	for _, a := range addrs {
+		if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			return ipNet.IP.String()
+		}
+	}
+	return ""
+}
+
+// tunnelBindAddress returns tunnel_bind_address or, when it is not set, an
+// address containers can reach through the exported host. On Linux, where
+// host.docker.internal is the docker0 bridge rather than the loopback, that is
+// the bridge address; everywhere else it is 127.0.0.1.
+func tunnelBindAddress(opt structs.Options) string {
+	if opt.TunnelBindAddress != "" {
+		return opt.TunnelBindAddress
+	}
+	exportHost := helpers.WithFallbackValue(opt.TunnelExportHost, "host.docker.internal")
+	if runtime.GOOS == "linux" && exportHost == "host.docker.internal" {
+		if bridge := dockerBridgeAddress(); bridge != "" {
+			return bridge
+		}
+		helpers.Warn("no docker0 bridge found; the SSH tunnel listens on 127.0.0.1, which containers cannot reach through host.docker.internal (set tunnel_bind_address)")
+	}
+	return "127.0.0.1"
+}
+
+// resolveTunnelEndpoint derives the listener and exported addresses from the options.
+func resolveTunnelEndpoint(opt structs.Options, remotePort int) tunnelEndpoint {
+	localPort := opt.TunnelLocalPort
+	if localPort <= 0 {
+		localPort = remotePort
+	}
+
+	bind := tunnelBindAddress(opt)
+	connectHost := bind
+	if bind == "0.0.0.0" || bind == "::" {
+		connectHost = "127.0.0.1"
+	}
+
+	return tunnelEndpoint{
+		Listen:     net.JoinHostPort(bind, strconv.Itoa(localPort)),
+		Connect:    net.JoinHostPort(connectHost, strconv.Itoa(localPort)),
+		ExportHost: helpers.WithFallbackValue(opt.TunnelExportHost, "host.docker.internal"),
+		ExportPort: localPort,
+	}
+}
+
+// rdsRoute is how this process (Connect*) and the containers (Export*) reach a
+// database; the two only differ when a private instance is tunnelled.
+type rdsRoute struct {
+	ConnectHost string
+	ConnectPort int
+	ExportHost  string
+	ExportPort  int
+}
+
+// startTunnel is the helper launcher used by routeRDS; tests replace it.
+var startTunnel = startTunnelHelper
+
+// routeRDS returns the route to host:port, starting (or reusing) the service's
+// SSH tunnel helper when tunnel_host is set.
+func routeRDS(opt structs.Options, host string, port int) (rdsRoute, error) {
+	route := rdsRoute{ConnectHost: host, ConnectPort: port, ExportHost: host, ExportPort: port}
+	if opt.TunnelHost == "" {
+		return route, nil
+	}
+
+	endpoint := resolveTunnelEndpoint(opt, port)
+	if err := startTunnel(opt, net.JoinHostPort(host, strconv.Itoa(port)), endpoint); err != nil {
+		return route, err
+	}
+
+	tunnelHost, tunnelPort, _ := net.SplitHostPort(endpoint.Connect)
+	route.ConnectHost = tunnelHost
+	route.ConnectPort, _ = strconv.Atoi(tunnelPort)
+	route.ExportHost, route.ExportPort = endpoint.ExportHost, endpoint.ExportPort
+	return route, nil
+}
+
+// freeLocalPort asks the OS for a port that is free on the bind address.
+func freeLocalPort(bind string) (int, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(bind, "0"))
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// tunnelAccepting reports whether something accepts connections on addr.
+func tunnelAccepting(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, 2*time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// startTunnelHelper launches this binary's hidden "tunnel" command as a detached
+// background process that forwards endpoint.Listen to remote through the
+// bastion, and waits until the listener accepts connections.
+func startTunnelHelper(opt structs.Options, remote string, endpoint tunnelEndpoint) error {
+	pidPath := tunnelStatePath(opt, "pid")
+	logPath := tunnelStatePath(opt, "log")
+
+	// Only reuse the listener when it belongs to our helper; a stale pid file
+	// next to an unrelated process on the port must not pass for a tunnel. A
+	// helper of ours that stopped accepting is killed before it is replaced,
+	// as the new pid file would otherwise orphan it.
+	if pid, start, err := readTunnelPid(pidPath); err == nil {
+		ours := isTunnelHelper(pid, start)
+		if ours && tunnelAccepting(endpoint.Connect) {
+			helpers.Info("reusing running SSH tunnel on %s (pid %d)", endpoint.Listen, pid)
+			return nil
+		}
+		if ours {
+			helpers.Info("replacing SSH tunnel helper (pid %d) that no longer accepts connections on %s", pid, endpoint.Listen)
+			killTunnelHelper(pid)
+		} else {
+			helpers.Debug("removing stale tunnel pid file %s (pid %d)", pidPath, pid)
+		}
+		_ = os.Remove(pidPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(pidPath), 0o700); err != nil {
+		return fmt.Errorf("create tunnel state directory: %w", err)
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate provider binary: %w", err)
+	}
+
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("open tunnel log: %w", err)
+	}
+	defer logFile.Close()
+
+	args := []string{
+		"tunnel",
+		"--tunnel_host", opt.TunnelHost,
+		"--tunnel_port", strconv.Itoa(opt.TunnelPort),
+		"--tunnel_user", opt.TunnelUser,
+		"--tunnel_key", opt.TunnelKey,
+		"--tunnel_known_hosts", opt.TunnelKnownHosts,
+		"--tunnel_insecure_host_key=" + strconv.FormatBool(opt.TunnelInsecureHostKey),
+		"--listen", endpoint.Listen,
+		"--remote", remote,
+	}
+
+	cmd := exec.Command(self, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = detachedProcAttr()
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start tunnel helper: %w", err)
+	}
+
+	if err := writeTunnelPid(pidPath, cmd.Process.Pid); err != nil {
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("write tunnel pid file: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	deadline := time.After(30 * time.Second)
+	for {
+		if tunnelAccepting(endpoint.Connect) {
+			helpers.Info("SSH tunnel %s -> %s via %s is up (pid %d, log %s)", endpoint.Listen, remote, opt.TunnelHost, cmd.Process.Pid, logPath)
+			_ = cmd.Process.Release()
+			return nil
+		}
+
+		select {
+		case err := <-exited:
+			_ = os.Remove(pidPath)
+			return fmt.Errorf("tunnel helper exited early (%v); see %s", err, logPath)
+		case <-deadline:
+			_ = cmd.Process.Kill()
+			_ = os.Remove(pidPath)
+			return fmt.Errorf("tunnel did not start listening on %s within 30s; see %s", endpoint.Listen, logPath)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+// writeTunnelPid records the helper's pid together with its start time, which
+// tells the helper apart from an unrelated process that later reuses the pid.
+func writeTunnelPid(pidPath string, pid int) error {
+	start, err := processStartTime(pid)
+	if err != nil {
+		return fmt.Errorf("read start time of pid %d: %w", pid, err)
+	}
+	return os.WriteFile(pidPath, []byte(strconv.Itoa(pid)+"\n"+start+"\n"), 0o600)
+}
+
+// readTunnelPid reads the pid and start time of a service's tunnel helper. Pid
+// files written before the start time was recorded return an empty start.
+func readTunnelPid(pidPath string) (int, string, error) {
+	raw, err := os.ReadFile(pidPath)
+	if err != nil {
+		return 0, "", err
+	}
+
+	pidLine, start, _ := strings.Cut(strings.TrimSpace(string(raw)), "\n")
+	pid, err := strconv.Atoi(strings.TrimSpace(pidLine))
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid tunnel pid file %s: %w", pidPath, err)
+	}
+	return pid, strings.TrimSpace(start), nil
+}
+
+// isTunnelHelper reports whether pid is still the helper recorded with start.
+// Without a recorded start time the process cannot be identified, so it is not
+// treated as ours.
+func isTunnelHelper(pid int, start string) bool {
+	if start == "" || !processAlive(pid) {
+		return false
+	}
+	current, err := processStartTime(pid)
+	return err == nil && current == start
+}
+
+// killTunnelHelper kills a helper already identified with isTunnelHelper.
+func killTunnelHelper(pid int) {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return
+	}
+	if err := proc.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		helpers.Debug("stopping tunnel helper %d: %v", pid, err)
+	}
+}
+
+// stopTunnelHelper terminates the background tunnel helper of a service, if
+// any. A pid that no longer belongs to the helper is left alone.
+func stopTunnelHelper(opt structs.Options) error {
+	pidPath := tunnelStatePath(opt, "pid")
+
+	pid, start, err := readTunnelPid(pidPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		_ = os.Remove(pidPath)
+		return err
+	}
+
+	if !isTunnelHelper(pid, start) {
+		helpers.Debug("tunnel pid file %s names pid %d, which is not our helper any more; not killing it", pidPath, pid)
+		return os.Remove(pidPath)
+	}
+
+	killTunnelHelper(pid)
+
+	helpers.Info("SSH tunnel helper (pid %d) stopped", pid)
+	return os.Remove(pidPath)
+}
+
+// sshClientConfig builds the SSH client configuration for the bastion.
+func sshClientConfig(opt structs.Options) (*ssh.ClientConfig, error) {
+	if opt.TunnelKey == "" {
+		return nil, fmt.Errorf("tunnel_key is required when tunnel_host is set")
+	}
+
+	key, err := os.ReadFile(expandHome(opt.TunnelKey))
+	if err != nil {
+		return nil, fmt.Errorf("read tunnel_key: %w", err)
+	}
+
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("parse tunnel_key: %w", err)
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if !opt.TunnelInsecureHostKey {
+		knownHostsPath := expandHome(helpers.WithFallbackValue(opt.TunnelKnownHosts, "~/.ssh/known_hosts"))
+		hostKeyCallback, err = knownhosts.New(knownHostsPath)
+		if err != nil {
+			return nil, fmt.Errorf("load known hosts %s: %w", knownHostsPath, err)
+		}
+	}
+
+	return &ssh.ClientConfig{
+		User:            helpers.WithFallbackValue(opt.TunnelUser, "ec2-user"),
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         15 * time.Second,
+	}, nil
+}
+
+// RunTunnel connects to the bastion and forwards every connection accepted on
+// listen to remote until ctx is cancelled. It is the body of the background
+// helper started by RDSUp.
+func RunTunnel(ctx context.Context, opt structs.Options, listen, remote string) error {
+	cfg, err := sshClientConfig(opt)
+	if err != nil {
+		helpers.Error("invalid tunnel options: %v", err)
+		return err
+	}
+
+	bastion := net.JoinHostPort(opt.TunnelHost, strconv.Itoa(opt.TunnelPort))
+
+	var mu sync.Mutex
+	var client *ssh.Client
+
+	// dialRemote reuses the SSH connection, reconnecting once if it dropped.
+	dialRemote := func() (net.Conn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if client != nil {
+			if conn, err := client.Dial("tcp", remote); err == nil {
+				return conn, nil
+			}
+			client.Close()
+			client = nil
+		}
+
+		c, err := ssh.Dial("tcp", bastion, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("connect to bastion %s: %w", bastion, err)
+		}
+		client = c
+		return client.Dial("tcp", remote)
+	}
+
+	// Fail fast on bad credentials / host keys before we start listening.
+	probe, err := dialRemote()
+	if err != nil {
+		helpers.Error("SSH tunnel setup failed: %v", err)
+		return err
+	}
+	probe.Close()
+
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		helpers.Error("tunnel listen on %s failed: %v", listen, err)
+		return err
+	}
+	defer listener.Close()
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	// Keep the SSH session alive through idle periods
+	go func() {
+		ticker := time.NewTicker(30 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				mu.Lock()
+				if client != nil {
+					_, _, _ = client.SendRequest("keepalive@openssh.com", true, nil)
+				}
+				mu.Unlock()
+			}
+		}
+	}()
+
+	helpers.Info("forwarding %s -> %s via %s", listen, remote, bastion)
+
+	for {
+		local, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			helpers.Error("tunnel accept failed: %v", err)
+			return err
+		}
+
+		go func() {
+			defer local.Close()
+
+			upstream, err := dialRemote()
+			if err != nil {
+				helpers.Error("tunnel dial %s failed: %v", remote, err)
+				return
+			}
+			defer upstream.Close()
+
+			done := make(chan struct{}, 2)
+			go func() { _, _ = io.Copy(upstream, local); done <- struct{}{} }()
+			go func() { _, _ = io.Copy(local, upstream); done <- struct{}{} }()
+			<-done
+		}()
+	}
+}
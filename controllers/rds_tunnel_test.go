@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/InspectorGadget/aws-compose-service/structs"
+)
+
+func TestRouteRDS(t *testing.T) {
+	var started []string
+	startTunnel = func(opt structs.Options, remote string, endpoint tunnelEndpoint) error {
+		started = append(started, opt.Name+" "+remote+" -> "+endpoint.Listen)
+		return nil
+	}
+	t.Cleanup(func() { startTunnel = startTunnelHelper })
+
+	tunnelled := structs.Options{
+		Name:              "db",
+		TunnelHost:        "bastion.example.com",
+		TunnelBindAddress: "127.0.0.1",
+		TunnelExportHost:  "host.docker.internal",
+	}
+
+	tests := []struct {
+		name        string
+		opt         structs.Options
+		want        rdsRoute
+		wantStarted string
+	}{
+		{
+			name: "direct",
+			opt:  structs.Options{Name: "db"},
+			want: rdsRoute{ConnectHost: "db.rds.example.com", ConnectPort: 5432, ExportHost: "db.rds.example.com", ExportPort: 5432},
+		},
+		{
+			name:        "tunnel on the instance port",
+			opt:         tunnelled,
+			want:        rdsRoute{ConnectHost: "127.0.0.1", ConnectPort: 5432, ExportHost: "host.docker.internal", ExportPort: 5432},
+			wantStarted: "db db.rds.example.com:5432 -> 127.0.0.1:5432",
+		},
+		{
+			name: "tunnel on a wildcard listener and local port",
+			opt: func() structs.Options {
+				opt := tunnelled
+				opt.TunnelBindAddress = "0.0.0.0"
+				opt.TunnelLocalPort = 15432
+				return opt
+			}(),
+			want:        rdsRoute{ConnectHost: "127.0.0.1", ConnectPort: 15432, ExportHost: "host.docker.internal", ExportPort: 15432},
+			wantStarted: "db db.rds.example.com:5432 -> 0.0.0.0:15432",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started = nil
+
+			got, err := routeRDS(tt.opt, "db.rds.example.com", 5432)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("route = %+v, want %+v", got, tt.want)
+			}
+
+			switch {
+			case tt.wantStarted == "" && len(started) != 0:
+				t.Errorf("tunnel started for a direct route: %v", started)
+			case tt.wantStarted != "" && (len(started) != 1 || started[0] != tt.wantStarted):
+				t.Errorf("tunnel started = %v, want [%s]", started, tt.wantStarted)
+			}
+		})
+	}
+}
+
+func TestGreenTunnelOptions(t *testing.T) {
+	opt := structs.Options{
+		Name:              "db",
+		TunnelHost:        "bastion.example.com",
+		TunnelBindAddress: "127.0.0.1",
+		TunnelLocalPort:   5432,
+	}
+
+	green, err := greenTunnelOptions(opt, "db-green-abc123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if green.TunnelLocalPort == 0 || green.TunnelLocalPort == opt.TunnelLocalPort {
+		t.Errorf("green tunnel port = %d, want a free port other than %d", green.TunnelLocalPort, opt.TunnelLocalPort)
+	}
+	if tunnelStatePath(green, "pid") == tunnelStatePath(opt, "pid") {
+		t.Errorf("green tunnel shares the service's pid file %s", tunnelStatePath(opt, "pid"))
+	}
+	if green.TunnelHost != opt.TunnelHost {
+		t.Errorf("green tunnel host = %q, want %q", green.TunnelHost, opt.TunnelHost)
+	}
+}
+
+func TestTunnelPidIdentity(t *testing.T) {
+	pidPath := filepath.Join(t.TempDir(), "tunnel.pid")
+
+	// The test process stands in for a running helper
+	if err := writeTunnelPid(pidPath, os.Getpid()); err != nil {
+		t.Fatalf("writeTunnelPid: %v", err)
+	}
+	pid, start, err := readTunnelPid(pidPath)
+	if err != nil {
+		t.Fatalf("readTunnelPid: %v", err)
+	}
+	if pid != os.Getpid() || start == "" {
+		t.Fatalf("readTunnelPid = %d, %q, want %d and a start time", pid, start, os.Getpid())
+	}
+	if !isTunnelHelper(pid, start) {
+		t.Errorf("isTunnelHelper(%d, %q) = false for the recorded process", pid, start)
+	}
+
+	// A reused pid has another start time, and an old pid file none at all
+	if isTunnelHelper(pid, start+"0") {
+		t.Errorf("isTunnelHelper accepted a pid with a different start time")
+	}
+	if err := os.WriteFile(pidPath, []byte(strconv.Itoa(pid)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if pid, start, err = readTunnelPid(pidPath); err != nil || start != "" {
+		t.Fatalf("readTunnelPid of a pid-only file = %d, %q, %v", pid, start, err)
+	}
+	if isTunnelHelper(pid, start) {
+		t.Errorf("isTunnelHelper accepted a pid without a start time")
+	}
+}
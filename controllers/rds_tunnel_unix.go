@@ -0,0 +1,52 @@
+//go:build !windows
+
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// detachedProcAttr starts the tunnel helper in its own session so it survives
+// the provider process exiting.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+// processAlive reports whether a process with the pid exists; signal 0 only
+// checks for it. EPERM means it exists but belongs to another user.
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// processStartTime returns an opaque token for when the process started: the
+// starttime field of /proc/<pid>/stat where there is a /proc, else what ps
+// reports as lstart.
+func processStartTime(pid int) (string, error) {
+	if raw, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid)); err == nil {
+		// The command name may contain spaces, so count from its closing
+		// parenthesis: the fields after it start at field 3 and starttime is 22
+		stat := string(raw)
+		fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+		if len(fields) < 20 {
+			return "", fmt.Errorf("unexpected /proc/%d/stat format", pid)
+		}
+		return fields[19], nil
+	}
+
+	out, err := exec.Command("ps", "-o", "lstart=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return "", err
+	}
+	start := strings.TrimSpace(string(out))
+	if start == "" {
+		return "", fmt.Errorf("no process %d", pid)
+	}
+	return start, nil
+}
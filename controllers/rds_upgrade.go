@@ -0,0 +1,342 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/rds"
+	rdstypes "github.com/aws/aws-sdk-go-v2/service/rds/types"
+)
+
+// blueGreenFailedStates are Blue/Green deployment statuses that will not recover.
+var blueGreenFailedStates = map[string]bool{
+	"INVALID_CONFIGURATION": true,
+	"PROVISIONING_FAILED":   true,
+	"SWITCHOVER_FAILED":     true,
+	"DELETING":              true,
+}
+
+// describeBlueGreenDeployment returns a single Blue/Green deployment by identifier.
+func describeBlueGreenDeployment(ctx context.Context, client *rds.Client, id string) (*rdstypes.BlueGreenDeployment, error) {
+	out, err := client.DescribeBlueGreenDeployments(ctx, &rds.DescribeBlueGreenDeploymentsInput{
+		BlueGreenDeploymentIdentifier: aws.String(id),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.BlueGreenDeployments) == 0 {
+		return nil, fmt.Errorf("blue/green deployment %s not found", id)
+	}
+	return &out.BlueGreenDeployments[0], nil
+}
+
+// waitForBlueGreenStatus polls the deployment until it reaches the wanted status.
+func waitForBlueGreenStatus(ctx context.Context, client *rds.Client, id, want string, timeout time.Duration) (*rdstypes.BlueGreenDeployment, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	last := ""
+	for {
+		deployment, err := describeBlueGreenDeployment(ctx, client, id)
+		if err != nil {
+			return nil, err
+		}
+
+		status := aws.ToString(deployment.Status)
+		if status == want {
+			return deployment, nil
+		}
+		if blueGreenFailedStates[status] {
+			return nil, fmt.Errorf("blue/green deployment %s is %s: %s", id, status, aws.ToString(deployment.StatusDetails))
+		}
+		if status != last {
+			helpers.Info("blue/green deployment %s is %s, waiting for %s", id, status, want)
+			last = status
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for blue/green deployment %s to become %s (last status: %s)", id, want, status)
+		case <-ticker.C:
+		}
+	}
+}
+
+// greenInstanceFor returns the green (target) instance paired with the blue instance ARN.
+func greenInstanceFor(ctx context.Context, client *rds.Client, deployment *rdstypes.BlueGreenDeployment, blueARN string) (*rdstypes.DBInstance, error) {
+	for _, d := range deployment.SwitchoverDetails {
+		if aws.ToString(d.SourceMember) == blueARN {
+			return describeRDSInstance(ctx, client, aws.ToString(d.TargetMember))
+		}
+	}
+	return nil, fmt.Errorf("no green instance found for %s", blueARN)
+}
+
+// greenTunnelOptions returns the options for the green instance's own tunnel:
+// its own helper state and a free local port, so it does not collide with the
+// service's tunnel to the blue instance.
+func greenTunnelOptions(opt structs.Options, greenID string) (structs.Options, error) {
+	tunnelOpt := opt
+	tunnelOpt.Name = greenID
+	tunnelOpt.TunnelBindAddress = tunnelBindAddress(opt)
+
+	port, err := freeLocalPort(tunnelOpt.TunnelBindAddress)
+	if err != nil {
+		return tunnelOpt, err
+	}
+	tunnelOpt.TunnelLocalPort = port
+	return tunnelOpt, nil
+}
+
+// probeGreenInstance checks that the green instance accepts connections, the
+// way RDSUp checks a new instance: through a tunnel of its own (on a free local
+// port, next to the service's tunnel to the blue instance) when tunnel_host is
+// set, and with TLS verification when require_tls is set.
+func probeGreenInstance(ctx context.Context, opt structs.Options, region, engine, dbName, username, password string, green *rdstypes.DBInstance) error {
+	host := aws.ToString(green.Endpoint.Address)
+	port := int(aws.ToInt32(green.Endpoint.Port))
+	if port == 0 {
+		port = engineSpecFor(engine).Port
+	}
+
+	greenTLS, err := prepareRDSTLS(ctx, opt, region, host)
+	if err != nil {
+		helpers.Error("preparing RDS TLS failed: %v", err)
+		return err
+	}
+
+	probe := rdsConnection{
+		Engine:   engine,
+		Host:     host,
+		Port:     port,
+		Username: username,
+		Password: password,
+		DBName:   dbName,
+	}
+	if greenTLS != nil {
+		probe.TLS = greenTLS.Config
+	}
+
+	if opt.TunnelHost != "" {
+		tunnelOpt, err := greenTunnelOptions(opt, aws.ToString(green.DBInstanceIdentifier))
+		if err != nil {
+			helpers.Error("finding a local port for the green SSH tunnel failed: %v", err)
+			return err
+		}
+
+		route, err := routeRDS(tunnelOpt, host, port)
+		if err != nil {
+			helpers.Error("starting SSH tunnel failed: %v", err)
+			return err
+		}
+		defer func() {
+			if err := stopTunnelHelper(tunnelOpt); err != nil {
+				helpers.Error("stopping SSH tunnel failed: %v", err)
+			}
+		}()
+
+		probe.Host, probe.Port = route.ConnectHost, route.ConnectPort
+	}
+
+	readiness := readinessSettings{
+		Mode:     opt.ReadinessCheck,
+		Timeout:  time.Duration(opt.ReadinessTimeout) * time.Second,
+		Interval: time.Duration(opt.ReadinessInterval) * time.Second,
+	}
+	return probeRDSReadiness(ctx, probe, readiness)
+}
+
+// RDSUpgrade performs a Blue/Green engine or parameter group upgrade of the
+// service's instance: it creates the green environment, waits for it, optionally
+// checks its readiness, switches over and exports the (unchanged) endpoints.
+func RDSUpgrade(ctx context.Context, opt structs.Options) error {
+	region := helpers.WithFallbackValue(opt.Region, "ap-southeast-1")
+	name := helpers.WithFallbackValue(opt.Name, "rds")
+
+	username := helpers.WithFallbackValue(opt.Username, "admin")
+	password := helpers.WithFallbackValue(opt.Password, "password")
+
+	if opt.EngineVersion == "" && opt.DBParameterGroup == "" {
+		helpers.Error("upgrade needs a target engine_version and/or db_parameter_group")
+		return fmt.Errorf("no upgrade target specified")
+	}
+
+	cfg, err := helpers.LoadAWSConfig(ctx, region)
+	if err != nil {
+		helpers.Error("unable to load AWS config: %v", err)
+		return err
+	}
+
+	client := rds.NewFromConfig(cfg)
+
+	// 1) The blue instance has to be available before we can branch from it
+	blue, err := findRDSInstance(ctx, client, name)
+	if err != nil {
+		helpers.Error("describe DB instances failed: %v", err)
+		return err
+	}
+	if blue == nil {
+		helpers.Error("RDS instance %s does not exist in %s; run up first", name, region)
+		return fmt.Errorf("db instance %s not found", name)
+	}
+
+	blue, err = ensureRDSInstanceReady(ctx, client, blue)
+	if err != nil {
+		return err
+	}
+
+	engine := aws.ToString(blue.Engine)
+	dbName := helpers.WithFallbackValue(opt.DBName, engineSpecFor(engine).DefaultDBName)
+	blueARN := aws.ToString(blue.DBInstanceArn)
+
+	// 2) Create the green environment
+	// Deployment names are limited to 60 characters; shorten the instance name,
+	// not the timestamp that keeps them unique
+	suffix := "-upgrade-" + time.Now().UTC().Format("20060102150405")
+	prefix := name
+	if len(prefix) > 60-len(suffix) {
+		prefix = strings.TrimRight(prefix[:60-len(suffix)], "-")
+	}
+	deploymentName := prefix + suffix
+
+	createInput := &rds.CreateBlueGreenDeploymentInput{
+		BlueGreenDeploymentName: aws.String(deploymentName),
+		Source:                  aws.String(blueARN),
+	}
+	if opt.EngineVersion != "" {
+		createInput.TargetEngineVersion = aws.String(opt.EngineVersion)
+	}
+	if opt.DBParameterGroup != "" {
+		createInput.TargetDBParameterGroupName = aws.String(opt.DBParameterGroup)
+	}
+
+	helpers.Info(
+		"creating blue/green deployment %s for %s (engine=%s %s -> %s, parameter_group=%s)",
+		deploymentName,
+		name,
+		engine,
+		aws.ToString(blue.EngineVersion),
+		helpers.WithFallbackValue(opt.EngineVersion, aws.ToString(blue.EngineVersion)),
+		helpers.WithFallbackValue(opt.DBParameterGroup, "unchanged"),
+	)
+
+	createOut, err := client.CreateBlueGreenDeployment(ctx, createInput)
+	if err != nil {
+		helpers.Error("create blue/green deployment failed: %v", err)
+		return err
+	}
+	deploymentID := aws.ToString(createOut.BlueGreenDeployment.BlueGreenDeploymentIdentifier)
+
+	// 3) Wait for the green environment
+	deployment, err := waitForBlueGreenStatus(ctx, client, deploymentID, "AVAILABLE", 2*time.Hour)
+	if err != nil {
+		helpers.Error("waiting for green environment failed: %v", err)
+		return err
+	}
+
+	// 4) Optionally check that the green instance accepts connections
+	green, err := greenInstanceFor(ctx, client, deployment, blueARN)
+	if err != nil {
+		helpers.Error("locating green instance failed: %v", err)
+		return err
+	}
+	helpers.Info("green instance %s is ready (engine version %s)", aws.ToString(green.DBInstanceIdentifier), aws.ToString(green.EngineVersion))
+
+	if green.Endpoint != nil {
+		if err := probeGreenInstance(ctx, opt, region, engine, dbName, username, password, green); err != nil {
+			helpers.Error("green environment failed its readiness check; blue/green deployment %s was left in place for inspection", deploymentID)
+			return err
+		}
+	}
+
+	// 5) Switch over
+	helpers.Info("switching over blue/green deployment %s", deploymentID)
+
+	_, err = client.SwitchoverBlueGreenDeployment(ctx, &rds.SwitchoverBlueGreenDeploymentInput{
+		BlueGreenDeploymentIdentifier: aws.String(deploymentID),
+		SwitchoverTimeout:             aws.Int32(int32(opt.SwitchoverTimeout)),
+	})
+	if err != nil {
+		helpers.Error("switchover failed: %v", err)
+		return err
+	}
+
+	if _, err := waitForBlueGreenStatus(ctx, client, deploymentID, "SWITCHOVER_COMPLETED", 30*time.Minute); err != nil {
+		helpers.Error("waiting for switchover failed: %v", err)
+		return err
+	}
+
+	// 6) The green instance now carries the original name and endpoint
+	instance, err := describeRDSInstance(ctx, client, name)
+	if err != nil {
+		helpers.Error("describe DB instance after switchover failed: %v", err)
+		return err
+	}
+	if instance.Endpoint == nil || instance.Endpoint.Address == nil {
+		helpers.Error("DB instance %s does not have an endpoint after switchover", name)
+		return fmt.Errorf("db instance has no endpoint")
+	}
+
+	host := aws.ToString(instance.Endpoint.Address)
+	port := int(aws.ToInt32(instance.Endpoint.Port))
+	if port == 0 {
+		port = engineSpecFor(engine).Port
+	}
+
+	tlsSetup, err := prepareRDSTLS(ctx, opt, region, host)
+	if err != nil {
+		helpers.Error("preparing RDS TLS failed: %v", err)
+		return err
+	}
+
+	// The service's tunnel forwards to the endpoint name, which the green
+	// instance took over, so an already running helper keeps working
+	route, err := routeRDS(opt, host, port)
+	if err != nil {
+		helpers.Error("starting SSH tunnel failed: %v", err)
+		return err
+	}
+
+	exportRDSEnv(rdsEnv{
+		Engine:             engine,
+		Host:               route.ExportHost,
+		Port:               route.ExportPort,
+		DBName:             dbName,
+		Username:           username,
+		Password:           password,
+		Region:             region,
+		Endpoint:           net.JoinHostPort(host, strconv.Itoa(port)),
+		InstanceIdentifier: name,
+		TLS:                opt.RequireTLS,
+		RootCertPath:       rootCertPath(tlsSetup),
+		OracleConnectBy:    opt.OracleConnectBy,
+	})
+	exportRDSTLSEnv(tlsSetup)
+
+	// The deployment record is no longer needed; the old blue instance is kept
+	// (renamed with an -old suffix) so it can be inspected before deleting it.
+	_, err = client.DeleteBlueGreenDeployment(ctx, &rds.DeleteBlueGreenDeploymentInput{
+		BlueGreenDeploymentIdentifier: aws.String(deploymentID),
+	})
+	if err != nil {
+		helpers.Error("delete blue/green deployment %s failed: %v", deploymentID, err)
+	}
+
+	helpers.Info(
+		"aws-compose-service (service=rds) upgraded %s to engine version %s; the previous instance was kept with an -old suffix",
+		name,
+		aws.ToString(instance.EngineVersion),
+	)
+
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/InspectorGadget/aws-compose-service/helpers"
 	"github.com/InspectorGadget/aws-compose-service/structs"
@@ -25,6 +26,23 @@ func deriveBucketName(opt structs.Options, region string) string {
 	return strings.ToLower(strings.ReplaceAll(base, "_", "-"))
 }
 
+// createS3Bucket creates a bucket in the given region.
+func createS3Bucket(ctx context.Context, client *s3.Client, bucket, region string) error {
+	createInput := &s3.CreateBucketInput{
+		Bucket: aws.String(bucket),
+	}
+
+	// For us-east-1, LocationConstraint must be omitted
+	if region != "us-east-1" {
+		createInput.CreateBucketConfiguration = &s3types.CreateBucketConfiguration{
+			LocationConstraint: s3types.BucketLocationConstraint(region),
+		}
+	}
+
+	_, err := client.CreateBucket(ctx, createInput)
+	return err
+}
+
 // S3Up ensures a bucket exists and exports its details as environment variables.
 func S3Up(ctx context.Context, opt structs.Options) error {
 	region := helpers.WithFallbackValue(opt.Region, "ap-southeast-1")
@@ -40,38 +58,85 @@ func S3Up(ctx context.Context, opt structs.Options) error {
 
 	client := s3.NewFromConfig(cfg)
 
-	// 1) Check if bucket exists
-	_, err = client.HeadBucket(ctx, &s3.HeadBucketInput{
-		Bucket: aws.String(bucket),
-	})
-	if err == nil {
-		// Bucket exists → fail
-		msg := fmt.Sprintf("S3 bucket %s already exists; aborting", bucket)
-		helpers.Error("%s", msg)
-		return fmt.Errorf("%s", msg)
+	// Reject invalid bucket settings before creating anything
+	if _, err := s3EncryptionRule(opt); err != nil {
+		helpers.Error("invalid encryption options: %v", err)
+		return err
+	}
+	if _, err := s3VersioningStatus(opt); err != nil {
+		helpers.Error("invalid versioning options: %v", err)
+		return err
+	}
+	if _, err := s3LifecycleRule(opt); err != nil {
+		helpers.Error("invalid lifecycle options: %v", err)
+		return err
+	}
+	if _, err := s3CORSRule(opt); err != nil {
+		helpers.Error("invalid CORS options: %v", err)
+		return err
 	}
 
-	// If HeadBucket returns an error, we *assume* bucket does not exist or is not accessible,
-	// and proceed to create it. (If creation fails, that will be surfaced below.)
-	helpers.Info("creating S3 bucket %s in region %s", bucket, region)
+	created, err := ensureS3Bucket(ctx, client, opt, bucket, region)
+	if err != nil {
+		helpers.Error("%v", err)
+		return err
+	}
 
-	createInput := &s3.CreateBucketInput{
-		Bucket: aws.String(bucket),
+	if err := applyS3Encryption(ctx, client, opt, bucket); err != nil {
+		helpers.Error("configure S3 encryption failed: %v", err)
+		return err
+	}
+	if err := applyS3AccessControls(ctx, client, opt, bucket); err != nil {
+		helpers.Error("configure S3 access controls failed: %v", err)
+		return err
+	}
+	if err := applyS3BucketPolicy(ctx, client, opt, bucket); err != nil {
+		helpers.Error("configure S3 bucket policy failed: %v", err)
+		return err
 	}
 
-	// For us-east-1, LocationConstraint must be omitted
-	if region != "us-east-1" {
-		createInput.CreateBucketConfiguration = &s3types.CreateBucketConfiguration{
-			LocationConstraint: s3types.BucketLocationConstraint(region),
-		}
+	if err := applyS3CORS(ctx, client, opt, bucket); err != nil {
+		helpers.Error("configure S3 CORS failed: %v", err)
+		return err
 	}
 
-	_, err = client.CreateBucket(ctx, createInput)
-	if err != nil {
-		helpers.Error("create S3 bucket failed: %v", err)
+	// Versioning first, so restored and seeded objects are versioned
+	if err := applyS3Versioning(ctx, client, opt, bucket); err != nil {
+		helpers.Error("configure S3 versioning failed: %v", err)
+		return err
+	}
+	if err := applyS3Lifecycle(ctx, client, opt, bucket); err != nil {
+		helpers.Error("configure S3 lifecycle failed: %v", err)
 		return err
 	}
 
+	// Cross-region replication to the DR bucket, set up before any object is
+	// written: replication only covers objects uploaded after the rule exists
+	if opt.DRRegion != "" {
+		if err := ensureS3Replication(ctx, cfg, client, opt, bucket, region); err != nil {
+			return err
+		}
+	}
+
+	// Restore only into a fresh bucket, so a second up does not overwrite data
+	if opt.RestoreFrom != "" {
+		if created {
+			if err := restoreS3Bucket(ctx, client, bucket, opt.RestoreFrom); err != nil {
+				helpers.Error("restore S3 bucket from %s failed: %v", opt.RestoreFrom, err)
+				return err
+			}
+		} else {
+			helpers.Info("S3 bucket %s already existed, skipping restore_from %s", bucket, opt.RestoreFrom)
+		}
+	}
+
+	if opt.SeedDir != "" {
+		if err := seedS3Bucket(ctx, client, bucket, opt.SeedDir, opt.SeedPrefix); err != nil {
+			helpers.Error("seed S3 bucket from %s failed: %v", opt.SeedDir, err)
+			return err
+		}
+	}
+
 	url := fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region)
 
 	// Legacy-style env vars
@@ -89,7 +154,52 @@ func S3Up(ctx context.Context, opt structs.Options) error {
 	return nil
 }
 
-// S3Down attempts to delete the bucket. It will fail if the bucket is not empty.
+// deleteS3Bucket deletes the bucket and waits until it is gone. With
+// empty_on_down the bucket is emptied first; otherwise S3 refuses to delete a
+// bucket that still holds objects.
+func deleteS3Bucket(ctx context.Context, client *s3.Client, opt structs.Options, bucket, region string) error {
+	if opt.EmptyOnDown {
+		err := checkS3BucketOwner(ctx, client, opt, bucket)
+		if isNoSuchBucket(err) {
+			helpers.Info("S3 bucket %s does not exist, nothing to delete", bucket)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := emptyS3Bucket(ctx, client, bucket); err != nil {
+			return err
+		}
+		helpers.Info("deleting S3 bucket %s in region %s", bucket, region)
+	} else {
+		helpers.Info("deleting S3 bucket %s in region %s (bucket must be empty, see empty_on_down)", bucket, region)
+	}
+
+	_, err := client.DeleteBucket(ctx, &s3.DeleteBucketInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		if isNoSuchBucket(err) {
+			helpers.Info("S3 bucket %s does not exist, nothing to delete", bucket)
+			return nil
+		}
+		return err
+	}
+
+	waiter := s3.NewBucketNotExistsWaiter(client)
+	waitErr := waiter.Wait(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(bucket),
+	}, 5*time.Minute)
+	if waitErr != nil {
+		return fmt.Errorf("waiting for S3 bucket %s deletion: %w", bucket, waitErr)
+	}
+
+	helpers.Info("S3 bucket %s deleted", bucket)
+	return nil
+}
+
+// S3Down deletes the bucket, emptying it first when empty_on_down is set.
 func S3Down(ctx context.Context, opt structs.Options) error {
 	region := helpers.WithFallbackValue(opt.Region, "ap-southeast-1")
 	bucket := deriveBucketName(opt, region)
@@ -102,24 +212,27 @@ func S3Down(ctx context.Context, opt structs.Options) error {
 
 	client := s3.NewFromConfig(cfg)
 
-	helpers.Info("deleting S3 bucket %s in region %s (bucket must be empty)", bucket, region)
+	if opt.BackupOnDown != "" {
+		err := backupS3Bucket(ctx, client, bucket, region, opt.BackupOnDown)
+		if isNoSuchBucket(err) {
+			helpers.Info("S3 bucket %s does not exist, nothing to back up", bucket)
+		} else if err != nil {
+			helpers.Error("back up S3 bucket to %s failed: %v", opt.BackupOnDown, err)
+			return err
+		}
+	}
 
-	_, err = client.DeleteBucket(ctx, &s3.DeleteBucketInput{
-		Bucket: aws.String(bucket),
-	})
-	if err != nil {
-		switch err.(type) {
-		case *s3types.NoSuchBucket:
-			helpers.Info("S3 bucket %s does not exist, nothing to delete", bucket)
-			return nil
-		default:
-			// real error, proceed
+	// The replica bucket and role depend on the source's replication rule
+	if opt.DRRegion != "" {
+		if err := teardownS3Replication(ctx, cfg, client, opt, bucket); err != nil {
+			return err
 		}
+	}
 
+	if err := deleteS3Bucket(ctx, client, opt, bucket, region); err != nil {
 		helpers.Error("delete S3 bucket failed: %v", err)
 		return err
 	}
 
-	helpers.Info("S3 bucket %s delete requested", bucket)
 	return nil
 }
@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3PublicReadResource is the object ARN pattern public_read exposes.
+func s3PublicReadResource(bucket, prefix string) string {
+	return "arn:aws:s3:::" + bucket + "/" + strings.TrimPrefix(prefix, "/") + "*"
+}
+
+// s3PublicReadStatement lets anyone read the objects below prefix.
+func s3PublicReadStatement(bucket, prefix string) s3PolicyStatement {
+	return s3PolicyStatement{
+		"Sid":       s3PolicySidPrefix + "PublicRead",
+		"Effect":    "Allow",
+		"Principal": "*",
+		"Action":    "s3:GetObject",
+		"Resource":  s3PublicReadResource(bucket, prefix),
+	}
+}
+
+// applyS3OwnershipControls disables ACLs, so the bucket owner owns every object
+// and access is governed by policies alone.
+func applyS3OwnershipControls(ctx context.Context, client *s3.Client, bucket string) error {
+	_, err := client.PutBucketOwnershipControls(ctx, &s3.PutBucketOwnershipControlsInput{
+		Bucket: aws.String(bucket),
+		OwnershipControls: &s3types.OwnershipControls{
+			Rules: []s3types.OwnershipControlsRule{
+				{ObjectOwnership: s3types.ObjectOwnershipBucketOwnerEnforced},
+			},
+		},
+	})
+	return err
+}
+
+// applyS3PublicAccessBlock blocks all public access. With public_read the
+// policy-related settings are relaxed so the read-only policy can take
+// effect; public ACLs stay blocked either way.
+func applyS3PublicAccessBlock(ctx context.Context, client *s3.Client, opt structs.Options, bucket string) error {
+	if opt.PublicRead {
+		helpers.Warn(
+			"public_read is set: ANYONE on the internet can read %s; do not store private data there",
+			s3PublicReadResource(bucket, opt.PublicReadPrefix),
+		)
+	}
+
+	_, err := client.PutPublicAccessBlock(ctx, &s3.PutPublicAccessBlockInput{
+		Bucket: aws.String(bucket),
+		PublicAccessBlockConfiguration: &s3types.PublicAccessBlockConfiguration{
+			BlockPublicAcls:       aws.Bool(true),
+			IgnorePublicAcls:      aws.Bool(true),
+			BlockPublicPolicy:     aws.Bool(!opt.PublicRead),
+			RestrictPublicBuckets: aws.Bool(!opt.PublicRead),
+		},
+	})
+	return err
+}
+
+// applyS3AccessControls locks the bucket down with ownership controls and the
+// public access block. Buckets taken over with adopt_bucket keep their
+// settings, which may rely on ACLs or public access, unless
+// manage_public_access asks to replace them.
+func applyS3AccessControls(ctx context.Context, client *s3.Client, opt structs.Options, bucket string) error {
+	if !opt.ManagePublicAccess {
+		tags, err := s3BucketTags(ctx, client, bucket)
+		if err != nil {
+			return fmt.Errorf("get tags of S3 bucket %s: %w", bucket, err)
+		}
+
+		if tags[s3AdoptedTag] != "" {
+			if opt.PublicRead {
+				return fmt.Errorf("public_read on adopted S3 bucket %s requires manage_public_access: true", bucket)
+			}
+			helpers.Warn(
+				"S3 bucket %s was adopted; leaving its ownership controls and public access block unchanged (set manage_public_access: true to disable ACLs and block public access)",
+				bucket,
+			)
+			return nil
+		}
+	}
+
+	if err := applyS3OwnershipControls(ctx, client, bucket); err != nil {
+		return fmt.Errorf("configure ownership controls: %w", err)
+	}
+	// The block has to be relaxed before a public policy can be put
+	if err := applyS3PublicAccessBlock(ctx, client, opt, bucket); err != nil {
+		return fmt.Errorf("configure public access block: %w", err)
+	}
+	return nil
+}
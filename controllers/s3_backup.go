@@ -0,0 +1,407 @@
+package controllers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// s3ManifestName and s3ObjectsDir lay out a backup: the manifest next to
+	// an objects/ tree holding one file per key.
+	s3ManifestName = "manifest.json"
+	s3ObjectsDir   = "objects"
+
+	// s3TransferConcurrency bounds the downloads / uploads in flight.
+	s3TransferConcurrency = 8
+)
+
+// s3BackupObject is the manifest entry of one object.
+type s3BackupObject struct {
+	Key                string            `json:"key"`
+	Size               int64             `json:"size"`
+	ETag               string            `json:"etag,omitempty"`
+	LastModified       time.Time         `json:"last_modified"`
+	StorageClass       string            `json:"storage_class,omitempty"`
+	ContentType        string            `json:"content_type,omitempty"`
+	ContentEncoding    string            `json:"content_encoding,omitempty"`
+	ContentDisposition string            `json:"content_disposition,omitempty"`
+	ContentLanguage    string            `json:"content_language,omitempty"`
+	CacheControl       string            `json:"cache_control,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+}
+
+// s3BackupManifest describes a bucket backup.
+type s3BackupManifest struct {
+	Bucket    string           `json:"bucket"`
+	Region    string           `json:"region"`
+	CreatedAt time.Time        `json:"created_at"`
+	Objects   []s3BackupObject `json:"objects"`
+}
+
+// isTarGz reports whether a backup path names an archive rather than a directory.
+func isTarGz(path string) bool {
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+// s3ObjectPath maps a key to its file in the backup, refusing keys that would
+// escape the objects/ tree. Keys ending in "/" (folder markers) have no file.
+func s3ObjectPath(root, key string) (string, error) {
+	if strings.HasSuffix(key, "/") {
+		return "", nil
+	}
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", fmt.Errorf("object key %q cannot be stored as a local file", key)
+	}
+	return filepath.Join(root, s3ObjectsDir, filepath.FromSlash(key)), nil
+}
+
+// newS3Uploader returns an uploader that switches to concurrent multipart
+// uploads for large files.
+func newS3Uploader(client *s3.Client) *manager.Uploader {
+	return manager.NewUploader(client, func(u *manager.Uploader) {
+		u.PartSize = 16 * 1024 * 1024
+		u.Concurrency = 4
+	})
+}
+
+// downloadS3Object saves one object under root and returns its manifest entry.
+func downloadS3Object(ctx context.Context, client *s3.Client, bucket, key, root string) (s3BackupObject, error) {
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return s3BackupObject{}, fmt.Errorf("get %s: %w", key, err)
+	}
+	defer out.Body.Close()
+
+	obj := s3BackupObject{
+		Key:                key,
+		Size:               aws.ToInt64(out.ContentLength),
+		ETag:               strings.Trim(aws.ToString(out.ETag), `"`),
+		LastModified:       aws.ToTime(out.LastModified),
+		StorageClass:       string(out.StorageClass),
+		ContentType:        aws.ToString(out.ContentType),
+		ContentEncoding:    aws.ToString(out.ContentEncoding),
+		ContentDisposition: aws.ToString(out.ContentDisposition),
+		ContentLanguage:    aws.ToString(out.ContentLanguage),
+		CacheControl:       aws.ToString(out.CacheControl),
+		Metadata:           out.Metadata,
+	}
+
+	path, err := s3ObjectPath(root, key)
+	if err != nil || path == "" {
+		return obj, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return obj, err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return obj, err
+	}
+	if _, err := io.Copy(f, out.Body); err != nil {
+		f.Close()
+		return obj, fmt.Errorf("download %s: %w", key, err)
+	}
+	return obj, f.Close()
+}
+
+// backupS3Bucket downloads every current object of the bucket into path (a
+// directory, or a .tar.gz / .tgz archive) together with a manifest of keys and
+// metadata, so restore_from can recreate the bucket later.
+func backupS3Bucket(ctx context.Context, client *s3.Client, bucket, region, path string) error {
+	root := path
+	if isTarGz(path) {
+		tmp, err := os.MkdirTemp("", "aws-compose-s3-backup-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+		root = tmp
+	}
+
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("list objects of %s: %w", bucket, err)
+		}
+		for _, o := range page.Contents {
+			keys = append(keys, aws.ToString(o.Key))
+		}
+	}
+
+	helpers.Info("backing up %d objects of S3 bucket %s to %s", len(keys), bucket, path)
+
+	manifest := s3BackupManifest{
+		Bucket:    bucket,
+		Region:    region,
+		CreatedAt: time.Now().UTC(),
+		Objects:   make([]s3BackupObject, len(keys)),
+	}
+
+	var total atomic.Int64
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(s3TransferConcurrency)
+	for i, key := range keys {
+		g.Go(func() error {
+			obj, err := downloadS3Object(gctx, client, bucket, key, root)
+			if err != nil {
+				return err
+			}
+			manifest.Objects[i] = obj
+			total.Add(obj.Size)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(root, s3ManifestName), data, 0o644); err != nil {
+		return err
+	}
+
+	if isTarGz(path) {
+		if err := writeTarGz(root, path); err != nil {
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+	}
+
+	helpers.Info("backed up %d objects (%d bytes) of S3 bucket %s to %s", len(keys), total.Load(), bucket, path)
+	return nil
+}
+
+// writeTarGz archives the contents of dir into a gzip-compressed tarball.
+func writeTarGz(dir, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		src, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// extractTarGz unpacks a backup archive into dir, refusing entries that would
+// land outside of it.
+func extractTarGz(path, dir string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.FromSlash(strings.TrimSuffix(header.Name, "/"))
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("archive entry %q escapes the backup directory", header.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+				return err
+			}
+			out, err := os.Create(target)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// restoreS3Bucket uploads a backup written by backupS3Bucket into the bucket,
+// restoring each object's content headers and user metadata.
+func restoreS3Bucket(ctx context.Context, client *s3.Client, bucket, path string) error {
+	root := path
+	if isTarGz(path) {
+		tmp, err := os.MkdirTemp("", "aws-compose-s3-restore-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+
+		if err := extractTarGz(path, tmp); err != nil {
+			return fmt.Errorf("extract %s: %w", path, err)
+		}
+		root = tmp
+	}
+
+	data, err := os.ReadFile(filepath.Join(root, s3ManifestName))
+	if err != nil {
+		return fmt.Errorf("read backup manifest: %w", err)
+	}
+
+	var manifest s3BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("parse backup manifest: %w", err)
+	}
+
+	helpers.Info("restoring %d objects from %s (backup of %s taken %s) into S3 bucket %s", len(manifest.Objects), path, manifest.Bucket, manifest.CreatedAt.Format(time.RFC3339), bucket)
+
+	uploader := newS3Uploader(client)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(s3TransferConcurrency)
+	for _, obj := range manifest.Objects {
+		g.Go(func() error {
+			input := &s3.PutObjectInput{
+				Bucket:   aws.String(bucket),
+				Key:      aws.String(obj.Key),
+				Metadata: obj.Metadata,
+			}
+			if obj.ContentType != "" {
+				input.ContentType = aws.String(obj.ContentType)
+			}
+			if obj.ContentEncoding != "" {
+				input.ContentEncoding = aws.String(obj.ContentEncoding)
+			}
+			if obj.ContentDisposition != "" {
+				input.ContentDisposition = aws.String(obj.ContentDisposition)
+			}
+			if obj.ContentLanguage != "" {
+				input.ContentLanguage = aws.String(obj.ContentLanguage)
+			}
+			if obj.CacheControl != "" {
+				input.CacheControl = aws.String(obj.CacheControl)
+			}
+
+			p, err := s3ObjectPath(root, obj.Key)
+			if err != nil {
+				return err
+			}
+			if p == "" {
+				input.Body = strings.NewReader("")
+				_, err = uploader.Upload(gctx, input)
+				return err
+			}
+
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			input.Body = f
+			if _, err := uploader.Upload(gctx, input); err != nil {
+				return fmt.Errorf("upload %s: %w", obj.Key, err)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	helpers.Info("restored %d objects into S3 bucket %s", len(manifest.Objects), bucket)
+	return nil
+}
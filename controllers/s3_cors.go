@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// s3CORSRuleID identifies the CORS rule managed by this provider; rules with
+// other IDs are left alone.
+const s3CORSRuleID = "aws-compose-service"
+
+// s3CORSMethods are the methods S3 accepts in a CORS rule.
+var s3CORSMethods = []string{"GET", "PUT", "POST", "DELETE", "HEAD"}
+
+// Defaults used when only frontend_origin is given: enough for a browser to
+// read objects and upload them directly (presigned PUT or POST), and to read
+// the ETag of each uploaded part of a multipart upload.
+var (
+	defaultCORSMethods       = []string{"GET", "HEAD", "PUT", "POST"}
+	defaultCORSHeaders       = []string{"*"}
+	defaultCORSExposeHeaders = []string{"ETag"}
+)
+
+const defaultCORSMaxAge = 3000
+
+// s3CORSRule builds the managed rule from the cors_* options, with defaults
+// derived from frontend_origin, or returns nil when neither is set.
+func s3CORSRule(opt structs.Options) (*s3types.CORSRule, error) {
+	origins := opt.CORSAllowedOrigins
+	if len(origins) == 0 && opt.FrontendOrigin != "" {
+		origins = []string{strings.TrimSuffix(opt.FrontendOrigin, "/")}
+	}
+
+	if len(origins) == 0 {
+		if len(opt.CORSAllowedMethods) > 0 || len(opt.CORSAllowedHeaders) > 0 || len(opt.CORSExposeHeaders) > 0 {
+			return nil, fmt.Errorf("cors_* options need cors_allowed_origins or frontend_origin")
+		}
+		return nil, nil
+	}
+
+	requested := opt.CORSAllowedMethods
+	if len(requested) == 0 {
+		requested = defaultCORSMethods
+	}
+	methods := make([]string, 0, len(requested))
+	for _, m := range requested {
+		m = strings.ToUpper(m)
+		if !slices.Contains(s3CORSMethods, m) {
+			return nil, fmt.Errorf("unsupported CORS method %q (supported: %s)", m, strings.Join(s3CORSMethods, ", "))
+		}
+		methods = append(methods, m)
+	}
+
+	headers := opt.CORSAllowedHeaders
+	if len(headers) == 0 {
+		headers = defaultCORSHeaders
+	}
+
+	expose := opt.CORSExposeHeaders
+	if len(expose) == 0 {
+		expose = defaultCORSExposeHeaders
+	}
+
+	maxAge := opt.CORSMaxAge
+	if maxAge <= 0 {
+		maxAge = defaultCORSMaxAge
+	}
+
+	return &s3types.CORSRule{
+		ID:             aws.String(s3CORSRuleID),
+		AllowedOrigins: origins,
+		AllowedMethods: methods,
+		AllowedHeaders: headers,
+		ExposeHeaders:  expose,
+		MaxAgeSeconds:  aws.Int32(int32(maxAge)),
+	}, nil
+}
+
+// applyS3CORS makes the managed CORS rule match the options, keeping any other
+// rules on the bucket, and exports the effective values for the frontend.
+func applyS3CORS(ctx context.Context, client *s3.Client, opt structs.Options, bucket string) error {
+	rule, err := s3CORSRule(opt)
+	if err != nil {
+		return err
+	}
+
+	var rules []s3types.CORSRule
+	managed := false
+
+	out, err := client.GetBucketCors(ctx, &s3.GetBucketCorsInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "NoSuchCORSConfiguration" {
+			return fmt.Errorf("get CORS configuration of %s: %w", bucket, err)
+		}
+	} else {
+		for _, r := range out.CORSRules {
+			if aws.ToString(r.ID) == s3CORSRuleID {
+				managed = true
+				continue
+			}
+			rules = append(rules, r)
+		}
+	}
+
+	if rule == nil && !managed {
+		return nil
+	}
+
+	if rule == nil {
+		helpers.Info("removing CORS rule %s from S3 bucket %s", s3CORSRuleID, bucket)
+	} else {
+		helpers.Info("applying CORS rule %s to S3 bucket %s (origins=%s)", s3CORSRuleID, bucket, strings.Join(rule.AllowedOrigins, ", "))
+		rules = append(rules, *rule)
+	}
+
+	if len(rules) == 0 {
+		_, err = client.DeleteBucketCors(ctx, &s3.DeleteBucketCorsInput{
+			Bucket: aws.String(bucket),
+		})
+		return err
+	}
+
+	_, err = client.PutBucketCors(ctx, &s3.PutBucketCorsInput{
+		Bucket:            aws.String(bucket),
+		CORSConfiguration: &s3types.CORSConfiguration{CORSRules: rules},
+	})
+	if err != nil {
+		return err
+	}
+
+	if rule != nil {
+		helpers.Setenv("S3_CORS_ALLOWED_ORIGINS", strings.Join(rule.AllowedOrigins, ","))
+		helpers.Setenv("S3_CORS_ALLOWED_METHODS", strings.Join(rule.AllowedMethods, ","))
+		helpers.Setenv("S3_CORS_ALLOWED_HEADERS", strings.Join(rule.AllowedHeaders, ","))
+		helpers.Setenv("S3_CORS_EXPOSE_HEADERS", strings.Join(rule.ExposeHeaders, ","))
+		helpers.Setenv("S3_CORS_MAX_AGE", strconv.Itoa(int(aws.ToInt32(rule.MaxAgeSeconds))))
+	}
+
+	return nil
+}
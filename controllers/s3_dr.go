@@ -0,0 +1,361 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3ReplicationRuleID identifies the replication rule managed by this provider.
+const s3ReplicationRuleID = "aws-compose-service-dr"
+
+const s3ReplicationTrustPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Principal": {"Service": "s3.amazonaws.com"},
+      "Action": "sts:AssumeRole"
+    }
+  ]
+}`
+
+// s3KMSStatement allows one KMS action set through S3 for the bucket's objects
+// only. Keys given by ID or alias cannot be matched by ARN, so the resource
+// falls back to "*" and the conditions do the scoping.
+func s3KMSStatement(actions []string, key, region, bucket string) s3PolicyStatement {
+	resource := "*"
+	if strings.HasPrefix(key, "arn:") && !strings.Contains(key, ":alias/") {
+		resource = key
+	}
+	return s3PolicyStatement{
+		"Effect":   "Allow",
+		"Action":   actions,
+		"Resource": resource,
+		"Condition": map[string]any{
+			"StringEquals": map[string]string{"kms:ViaService": "s3." + region + ".amazonaws.com"},
+			// Bucket Keys use the bucket ARN as encryption context, objects their own ARN
+			"StringLike": map[string][]string{
+				"kms:EncryptionContext:aws:s3:arn": {"arn:aws:s3:::" + bucket, "arn:aws:s3:::" + bucket + "/*"},
+			},
+		},
+	}
+}
+
+// s3ReplicationPolicy is the inline policy of the replication role. With
+// sse-kms it may also decrypt source objects and encrypt their replicas.
+func s3ReplicationPolicy(opt structs.Options, bucket, region, drBucket string) (string, error) {
+	policy := s3Policy{
+		Version: "2012-10-17",
+		Statement: []s3PolicyStatement{
+			{
+				"Effect":   "Allow",
+				"Action":   []string{"s3:GetReplicationConfiguration", "s3:ListBucket"},
+				"Resource": "arn:aws:s3:::" + bucket,
+			},
+			{
+				"Effect":   "Allow",
+				"Action":   []string{"s3:GetObjectVersionForReplication", "s3:GetObjectVersionAcl", "s3:GetObjectVersionTagging"},
+				"Resource": "arn:aws:s3:::" + bucket + "/*",
+			},
+			{
+				"Effect":   "Allow",
+				"Action":   []string{"s3:ReplicateObject", "s3:ReplicateDelete", "s3:ReplicateTags"},
+				"Resource": "arn:aws:s3:::" + drBucket + "/*",
+			},
+		},
+	}
+
+	if strings.EqualFold(opt.Encryption, "sse-kms") {
+		policy.Statement = append(policy.Statement,
+			s3KMSStatement([]string{"kms:Decrypt"}, opt.KMSKeyID, region, bucket),
+			s3KMSStatement([]string{"kms:Encrypt", "kms:GenerateDataKey"}, opt.DRKMSKeyID, opt.DRRegion, drBucket),
+		)
+	}
+
+	document, err := json.Marshal(policy)
+	if err != nil {
+		return "", err
+	}
+	return string(document), nil
+}
+
+// s3DROptions are the options the DR bucket is configured with: the source
+// bucket's encryption (with dr_kms_key_id as the key) and TLS policy, but never
+// public read access.
+func s3DROptions(opt structs.Options) structs.Options {
+	drOpt := opt
+	drOpt.KMSKeyID = opt.DRKMSKeyID
+	drOpt.PublicRead = false
+	drOpt.PublicReadPrefix = ""
+	return drOpt
+}
+
+// s3ReplicaKMSKeyID returns the key replicas are encrypted with in dr_region.
+// Replication needs an explicit key, so the aws/s3 managed key is addressed by
+// its alias ARN, built from the account and partition of the replication role.
+func s3ReplicaKMSKeyID(opt structs.Options, roleARN string) (string, error) {
+	if opt.DRKMSKeyID != "" {
+		return opt.DRKMSKeyID, nil
+	}
+
+	role, err := arn.Parse(roleARN)
+	if err != nil {
+		return "", fmt.Errorf("parse replication role ARN %s: %w", roleARN, err)
+	}
+	return fmt.Sprintf("arn:%s:kms:%s:%s:alias/aws/s3", role.Partition, opt.DRRegion, role.AccountID), nil
+}
+
+// secureS3DRBucket applies the source bucket's encryption, ownership controls,
+// public access block and TLS policy to the DR bucket.
+func secureS3DRBucket(ctx context.Context, client *s3.Client, opt structs.Options, drBucket string) error {
+	drOpt := s3DROptions(opt)
+
+	if err := applyS3Encryption(ctx, client, drOpt, drBucket); err != nil {
+		return fmt.Errorf("configure encryption of %s: %w", drBucket, err)
+	}
+	if err := applyS3AccessControls(ctx, client, drOpt, drBucket); err != nil {
+		return fmt.Errorf("configure access controls of %s: %w", drBucket, err)
+	}
+	if err := applyS3BucketPolicy(ctx, client, drOpt, drBucket); err != nil {
+		return fmt.Errorf("configure bucket policy of %s: %w", drBucket, err)
+	}
+	return nil
+}
+
+// deriveDRBucketName returns the replica bucket in the DR region. Derived names
+// already carry the region; explicit bucket names get a -dr suffix.
+func deriveDRBucketName(opt structs.Options) string {
+	if opt.BucketName != "" {
+		return opt.BucketName + "-dr"
+	}
+	return deriveBucketName(opt, opt.DRRegion)
+}
+
+// s3ReplicationRoleName is the IAM role S3 assumes to replicate the bucket.
+func s3ReplicationRoleName(bucket string) string {
+	name := bucket + "-replication"
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+// enableS3Versioning turns on versioning, which replication requires on both buckets.
+func enableS3Versioning(ctx context.Context, client *s3.Client, bucket string) error {
+	_, err := client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucket),
+		VersioningConfiguration: &s3types.VersioningConfiguration{
+			Status: s3types.BucketVersioningStatusEnabled,
+		},
+	})
+	return err
+}
+
+// ensureS3ReplicationRole creates (or updates) the replication role and its
+// inline policy and returns the role ARN.
+func ensureS3ReplicationRole(ctx context.Context, client *iam.Client, roleName, bucket, drBucket, policy string) (string, error) {
+	var arn string
+
+	getOut, err := client.GetRole(ctx, &iam.GetRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err == nil {
+		arn = aws.ToString(getOut.Role.Arn)
+	} else {
+		var notFound *iamtypes.NoSuchEntityException
+		if !errors.As(err, &notFound) {
+			return "", fmt.Errorf("get IAM role %s: %w", roleName, err)
+		}
+
+		helpers.Info("creating IAM role %s for S3 replication", roleName)
+
+		createOut, err := client.CreateRole(ctx, &iam.CreateRoleInput{
+			RoleName:                 aws.String(roleName),
+			AssumeRolePolicyDocument: aws.String(s3ReplicationTrustPolicy),
+			Description:              aws.String(fmt.Sprintf("Allows S3 to replicate %s to %s", bucket, drBucket)),
+		})
+		if err != nil {
+			return "", fmt.Errorf("create IAM role %s: %w", roleName, err)
+		}
+		arn = aws.ToString(createOut.Role.Arn)
+
+		waiter := iam.NewRoleExistsWaiter(client)
+		if err := waiter.Wait(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)}, 2*time.Minute); err != nil {
+			return "", fmt.Errorf("waiting for IAM role %s: %w", roleName, err)
+		}
+	}
+
+	_, err = client.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+		RoleName:       aws.String(roleName),
+		PolicyName:     aws.String(s3ReplicationRuleID),
+		PolicyDocument: aws.String(policy),
+	})
+	if err != nil {
+		return "", fmt.Errorf("put policy on IAM role %s: %w", roleName, err)
+	}
+
+	return arn, nil
+}
+
+// ensureS3Replication creates the DR bucket in dr_region with the source
+// bucket's security settings, enables versioning on both buckets and
+// replicates every object of the source bucket to it, SSE-KMS ones included.
+func ensureS3Replication(ctx context.Context, cfg aws.Config, client *s3.Client, opt structs.Options, bucket, region string) error {
+	if opt.DRRegion == region {
+		helpers.Error("dr_region must differ from region (%s)", region)
+		return fmt.Errorf("dr_region equals region %s", region)
+	}
+
+	drBucket := deriveDRBucketName(opt)
+
+	drCfg, err := helpers.LoadAWSConfig(ctx, opt.DRRegion)
+	if err != nil {
+		helpers.Error("unable to load AWS config for %s: %v", opt.DRRegion, err)
+		return err
+	}
+	drClient := s3.NewFromConfig(drCfg)
+
+	if _, err := ensureS3Bucket(ctx, drClient, opt, drBucket, opt.DRRegion); err != nil {
+		helpers.Error("preparing DR bucket failed: %v", err)
+		return err
+	}
+	if err := secureS3DRBucket(ctx, drClient, opt, drBucket); err != nil {
+		helpers.Error("preparing DR bucket failed: %v", err)
+		return err
+	}
+
+	if err := enableS3Versioning(ctx, client, bucket); err != nil {
+		helpers.Error("enable versioning on %s failed: %v", bucket, err)
+		return err
+	}
+	if err := enableS3Versioning(ctx, drClient, drBucket); err != nil {
+		helpers.Error("enable versioning on %s failed: %v", drBucket, err)
+		return err
+	}
+
+	policy, err := s3ReplicationPolicy(opt, bucket, region, drBucket)
+	if err != nil {
+		return err
+	}
+
+	roleARN, err := ensureS3ReplicationRole(ctx, iam.NewFromConfig(cfg), s3ReplicationRoleName(bucket), bucket, drBucket, policy)
+	if err != nil {
+		helpers.Error("preparing S3 replication role failed: %v", err)
+		return err
+	}
+
+	rule := s3types.ReplicationRule{
+		ID:       aws.String(s3ReplicationRuleID),
+		Status:   s3types.ReplicationRuleStatusEnabled,
+		Priority: aws.Int32(1),
+		Filter:   &s3types.ReplicationRuleFilter{Prefix: aws.String("")},
+		DeleteMarkerReplication: &s3types.DeleteMarkerReplication{
+			Status: s3types.DeleteMarkerReplicationStatusEnabled,
+		},
+		Destination: &s3types.Destination{
+			Bucket: aws.String("arn:aws:s3:::" + drBucket),
+		},
+	}
+
+	// S3 skips SSE-KMS objects unless the rule opts in and names the replica key
+	if strings.EqualFold(opt.Encryption, "sse-kms") {
+		replicaKey, err := s3ReplicaKMSKeyID(opt, roleARN)
+		if err != nil {
+			helpers.Error("%v", err)
+			return err
+		}
+		rule.SourceSelectionCriteria = &s3types.SourceSelectionCriteria{
+			SseKmsEncryptedObjects: &s3types.SseKmsEncryptedObjects{
+				Status: s3types.SseKmsEncryptedObjectsStatusEnabled,
+			},
+		}
+		rule.Destination.EncryptionConfiguration = &s3types.EncryptionConfiguration{
+			ReplicaKmsKeyID: aws.String(replicaKey),
+		}
+	}
+
+	helpers.Info("replicating S3 bucket %s to %s (%s)", bucket, drBucket, opt.DRRegion)
+
+	_, err = client.PutBucketReplication(ctx, &s3.PutBucketReplicationInput{
+		Bucket: aws.String(bucket),
+		ReplicationConfiguration: &s3types.ReplicationConfiguration{
+			Role:  aws.String(roleARN),
+			Rules: []s3types.ReplicationRule{rule},
+		},
+	})
+	if err != nil {
+		helpers.Error("configure S3 replication failed: %v", err)
+		return err
+	}
+
+	helpers.Setenv("S3_DR_BUCKET_NAME", drBucket)
+	helpers.Setenv("S3_DR_BUCKET_REGION", opt.DRRegion)
+	helpers.Setenv("S3_DR_BUCKET_URL", fmt.Sprintf("https://%s.s3.%s.amazonaws.com", drBucket, opt.DRRegion))
+
+	return nil
+}
+
+// teardownS3Replication removes the replication rule, then the replication role
+// and the DR bucket, so the source bucket can be deleted afterwards.
+func teardownS3Replication(ctx context.Context, cfg aws.Config, client *s3.Client, opt structs.Options, bucket string) error {
+	drBucket := deriveDRBucketName(opt)
+	roleName := s3ReplicationRoleName(bucket)
+
+	helpers.Info("removing replication from S3 bucket %s to %s", bucket, drBucket)
+
+	_, err := client.DeleteBucketReplication(ctx, &s3.DeleteBucketReplicationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		if !isNoSuchBucket(err) {
+			helpers.Error("delete S3 replication failed: %v", err)
+			return err
+		}
+	}
+
+	iamClient := iam.NewFromConfig(cfg)
+	var notFound *iamtypes.NoSuchEntityException
+
+	_, err = iamClient.DeleteRolePolicy(ctx, &iam.DeleteRolePolicyInput{
+		RoleName:   aws.String(roleName),
+		PolicyName: aws.String(s3ReplicationRuleID),
+	})
+	if err != nil && !errors.As(err, &notFound) {
+		helpers.Error("delete policy of IAM role %s failed: %v", roleName, err)
+		return err
+	}
+
+	_, err = iamClient.DeleteRole(ctx, &iam.DeleteRoleInput{
+		RoleName: aws.String(roleName),
+	})
+	if err != nil && !errors.As(err, &notFound) {
+		helpers.Error("delete IAM role %s failed: %v", roleName, err)
+		return err
+	}
+
+	drCfg, err := helpers.LoadAWSConfig(ctx, opt.DRRegion)
+	if err != nil {
+		helpers.Error("unable to load AWS config for %s: %v", opt.DRRegion, err)
+		return err
+	}
+
+	if err := deleteS3Bucket(ctx, s3.NewFromConfig(drCfg), opt, drBucket, opt.DRRegion); err != nil {
+		helpers.Error("delete DR bucket failed: %v", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// s3DeleteBatchSize is the most keys DeleteObjects accepts per call.
+	s3DeleteBatchSize = 1000
+	// s3EmptyConcurrency bounds the DeleteObjects calls in flight.
+	s3EmptyConcurrency = 8
+)
+
+// deleteS3ObjectBatch deletes up to s3DeleteBatchSize objects / versions and
+// reports the first per-key failure, since DeleteObjects returns 200 even when
+// some keys could not be deleted.
+func deleteS3ObjectBatch(ctx context.Context, client *s3.Client, bucket string, objects []s3types.ObjectIdentifier) error {
+	out, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(bucket),
+		Delete: &s3types.Delete{
+			Objects: objects,
+			Quiet:   aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(out.Errors) > 0 {
+		first := out.Errors[0]
+		return fmt.Errorf(
+			"%d of %d objects not deleted, e.g. %s (version %s): %s",
+			len(out.Errors),
+			len(objects),
+			aws.ToString(first.Key),
+			aws.ToString(first.VersionId),
+			aws.ToString(first.Message),
+		)
+	}
+	return nil
+}
+
+// emptyS3Bucket deletes every object version and delete marker of the bucket
+// (plain objects of unversioned buckets come back as version "null") and
+// aborts in-progress multipart uploads, so the bucket itself can be deleted.
+func emptyS3Bucket(ctx context.Context, client *s3.Client, bucket string) error {
+	helpers.Info("emptying S3 bucket %s", bucket)
+
+	var deleted atomic.Int64
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(s3EmptyConcurrency)
+
+	flush := func(batch []s3types.ObjectIdentifier) {
+		g.Go(func() error {
+			if err := deleteS3ObjectBatch(gctx, client, bucket, batch); err != nil {
+				return err
+			}
+			deleted.Add(int64(len(batch)))
+			return nil
+		})
+	}
+
+	var batch []s3types.ObjectIdentifier
+	paginator := s3.NewListObjectVersionsPaginator(client, &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucket),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(gctx)
+		if err != nil {
+			// A failed batch cancels gctx, which fails the listing too; report
+			// the batch error as the cause
+			if werr := g.Wait(); werr != nil {
+				return fmt.Errorf("delete objects of %s: %w", bucket, werr)
+			}
+			return fmt.Errorf("list object versions of %s: %w", bucket, err)
+		}
+
+		for _, v := range page.Versions {
+			batch = append(batch, s3types.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+			if len(batch) == s3DeleteBatchSize {
+				flush(batch)
+				batch = nil
+			}
+		}
+		for _, m := range page.DeleteMarkers {
+			batch = append(batch, s3types.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+			if len(batch) == s3DeleteBatchSize {
+				flush(batch)
+				batch = nil
+			}
+		}
+	}
+	if len(batch) > 0 {
+		flush(batch)
+	}
+
+	if err := g.Wait(); err != nil {
+		return fmt.Errorf("delete objects of %s: %w", bucket, err)
+	}
+
+	aborted := 0
+	uploads := s3.NewListMultipartUploadsPaginator(client, &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(bucket),
+	})
+	for uploads.HasMorePages() {
+		page, err := uploads.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("list multipart uploads of %s: %w", bucket, err)
+		}
+
+		for _, u := range page.Uploads {
+			_, err := client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(bucket),
+				Key:      u.Key,
+				UploadId: u.UploadId,
+			})
+			if err != nil {
+				return fmt.Errorf("abort multipart upload of %s: %w", aws.ToString(u.Key), err)
+			}
+			aborted++
+		}
+	}
+
+	helpers.Info("S3 bucket %s emptied (%d objects / versions deleted, %d multipart uploads aborted)", bucket, deleted.Load(), aborted)
+	return nil
+}
@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3EncryptionRule builds the default encryption rule from encryption,
+// kms_key_id and bucket_key, or returns nil when encryption is not set.
+func s3EncryptionRule(opt structs.Options) (*s3types.ServerSideEncryptionRule, error) {
+	mode := strings.ToLower(opt.Encryption)
+
+	if opt.KMSKeyID != "" && mode != "sse-kms" {
+		return nil, fmt.Errorf("kms_key_id requires encryption: sse-kms")
+	}
+	if opt.DRKMSKeyID != "" && (mode != "sse-kms" || opt.DRRegion == "") {
+		return nil, fmt.Errorf("dr_kms_key_id requires encryption: sse-kms and dr_region")
+	}
+
+	switch mode {
+	case "":
+		return nil, nil
+	case "sse-s3":
+		return &s3types.ServerSideEncryptionRule{
+			ApplyServerSideEncryptionByDefault: &s3types.ServerSideEncryptionByDefault{
+				SSEAlgorithm: s3types.ServerSideEncryptionAes256,
+			},
+		}, nil
+	case "sse-kms":
+		byDefault := &s3types.ServerSideEncryptionByDefault{
+			SSEAlgorithm: s3types.ServerSideEncryptionAwsKms,
+		}
+		// Without a key, S3 uses the AWS managed aws/s3 key
+		if opt.KMSKeyID != "" {
+			byDefault.KMSMasterKeyID = aws.String(opt.KMSKeyID)
+		}
+		return &s3types.ServerSideEncryptionRule{
+			ApplyServerSideEncryptionByDefault: byDefault,
+			BucketKeyEnabled:                   aws.Bool(opt.BucketKey),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported encryption %q (expected: sse-s3 or sse-kms)", opt.Encryption)
+	}
+}
+
+// applyS3Encryption sets the bucket's default encryption.
+func applyS3Encryption(ctx context.Context, client *s3.Client, opt structs.Options, bucket string) error {
+	rule, err := s3EncryptionRule(opt)
+	if err != nil || rule == nil {
+		return err
+	}
+
+	helpers.Info(
+		"setting default encryption of S3 bucket %s to %s (kms_key_id=%s bucket_key=%t)",
+		bucket,
+		strings.ToLower(opt.Encryption),
+		helpers.WithFallbackValue(opt.KMSKeyID, "-"),
+		aws.ToBool(rule.BucketKeyEnabled),
+	)
+
+	_, err = client.PutBucketEncryption(ctx, &s3.PutBucketEncryptionInput{
+		Bucket: aws.String(bucket),
+		ServerSideEncryptionConfiguration: &s3types.ServerSideEncryptionConfiguration{
+			Rules: []s3types.ServerSideEncryptionRule{*rule},
+		},
+	})
+	return err
+}
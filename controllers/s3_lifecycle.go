@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// s3LifecycleRuleID identifies the lifecycle rule managed by this provider;
+// rules with other IDs are left alone.
+const s3LifecycleRuleID = "aws-compose-service"
+
+// s3VersioningStatus parses the versioning option, or returns "" when it is
+// not set. Versioning cannot be turned off once enabled, only suspended, and
+// replication to dr_region needs it enabled.
+func s3VersioningStatus(opt structs.Options) (s3types.BucketVersioningStatus, error) {
+	switch strings.ToLower(opt.Versioning) {
+	case "":
+		return "", nil
+	case "enabled":
+		return s3types.BucketVersioningStatusEnabled, nil
+	case "suspended":
+		if opt.DRRegion != "" {
+			return "", fmt.Errorf("versioning cannot be suspended while dr_region replication is configured")
+		}
+		return s3types.BucketVersioningStatusSuspended, nil
+	default:
+		return "", fmt.Errorf("unsupported versioning %q (expected: enabled or suspended)", opt.Versioning)
+	}
+}
+
+// applyS3Versioning sets the bucket's versioning state.
+func applyS3Versioning(ctx context.Context, client *s3.Client, opt structs.Options, bucket string) error {
+	status, err := s3VersioningStatus(opt)
+	if err != nil || status == "" {
+		return err
+	}
+
+	helpers.Info("setting versioning of S3 bucket %s to %s", bucket, status)
+
+	_, err = client.PutBucketVersioning(ctx, &s3.PutBucketVersioningInput{
+		Bucket: aws.String(bucket),
+		VersioningConfiguration: &s3types.VersioningConfiguration{
+			Status: status,
+		},
+	})
+	return err
+}
+
+// parseS3Transitions parses lifecycle_transitions entries of the form
+// "<days>:<storage class>", e.g. "30:STANDARD_IA".
+func parseS3Transitions(entries []string) ([]s3types.Transition, error) {
+	classes := s3types.TransitionStorageClass("").Values()
+
+	var transitions []s3types.Transition
+	for _, e := range entries {
+		daysPart, class, ok := strings.Cut(e, ":")
+		days, err := strconv.Atoi(strings.TrimSpace(daysPart))
+		if !ok || err != nil || days < 0 {
+			return nil, fmt.Errorf("invalid lifecycle transition %q (expected <days>:<storage class>)", e)
+		}
+
+		storageClass := s3types.TransitionStorageClass(strings.ToUpper(strings.TrimSpace(class)))
+		if !slices.Contains(classes, storageClass) {
+			return nil, fmt.Errorf("invalid storage class in lifecycle transition %q (supported: %v)", e, classes)
+		}
+
+		transitions = append(transitions, s3types.Transition{
+			Days:         aws.Int32(int32(days)),
+			StorageClass: storageClass,
+		})
+	}
+	return transitions, nil
+}
+
+// s3LifecycleFilter builds the rule filter from lifecycle_prefix and
+// lifecycle_tags ("key=value" entries); several conditions are ANDed.
+func s3LifecycleFilter(prefix string, tagEntries []string) (*s3types.LifecycleRuleFilter, error) {
+	var tags []s3types.Tag
+	for _, e := range tagEntries {
+		k, v, ok := strings.Cut(e, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid lifecycle tag %q (expected key=value)", e)
+		}
+		tags = append(tags, s3types.Tag{Key: aws.String(strings.TrimSpace(k)), Value: aws.String(strings.TrimSpace(v))})
+	}
+
+	switch {
+	case len(tags) == 0:
+		return &s3types.LifecycleRuleFilter{Prefix: aws.String(prefix)}, nil
+	case len(tags) == 1 && prefix == "":
+		return &s3types.LifecycleRuleFilter{Tag: &tags[0]}, nil
+	default:
+		and := &s3types.LifecycleRuleAndOperator{Tags: tags}
+		if prefix != "" {
+			and.Prefix = aws.String(prefix)
+		}
+		return &s3types.LifecycleRuleFilter{And: and}, nil
+	}
+}
+
+// s3LifecycleRule builds the managed rule from the lifecycle_* options, or
+// returns nil when none is set.
+func s3LifecycleRule(opt structs.Options) (*s3types.LifecycleRule, error) {
+	transitions, err := parseS3Transitions(opt.LifecycleTransitions)
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.LifecycleExpirationDays <= 0 &&
+		opt.LifecycleNoncurrentExpirationDays <= 0 &&
+		opt.LifecycleAbortMultipartDays <= 0 &&
+		len(transitions) == 0 {
+		return nil, nil
+	}
+
+	filter, err := s3LifecycleFilter(opt.LifecyclePrefix, opt.LifecycleTags)
+	if err != nil {
+		return nil, err
+	}
+
+	rule := &s3types.LifecycleRule{
+		ID:          aws.String(s3LifecycleRuleID),
+		Status:      s3types.ExpirationStatusEnabled,
+		Filter:      filter,
+		Transitions: transitions,
+	}
+
+	if opt.LifecycleExpirationDays > 0 {
+		rule.Expiration = &s3types.LifecycleExpiration{Days: aws.Int32(int32(opt.LifecycleExpirationDays))}
+	}
+	if opt.LifecycleNoncurrentExpirationDays > 0 {
+		rule.NoncurrentVersionExpiration = &s3types.NoncurrentVersionExpiration{
+			NoncurrentDays: aws.Int32(int32(opt.LifecycleNoncurrentExpirationDays)),
+		}
+	}
+	if opt.LifecycleAbortMultipartDays > 0 {
+		// S3 rejects tag filters on rules that abort multipart uploads
+		if len(opt.LifecycleTags) > 0 {
+			return nil, fmt.Errorf("lifecycle_abort_multipart_days cannot be combined with lifecycle_tags")
+		}
+		rule.AbortIncompleteMultipartUpload = &s3types.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: aws.Int32(int32(opt.LifecycleAbortMultipartDays)),
+		}
+	}
+
+	return rule, nil
+}
+
+// applyS3Lifecycle makes the managed lifecycle rule match the options, keeping
+// any other rules on the bucket. Clearing every lifecycle_* option removes the
+// managed rule.
+func applyS3Lifecycle(ctx context.Context, client *s3.Client, opt structs.Options, bucket string) error {
+	rule, err := s3LifecycleRule(opt)
+	if err != nil {
+		return err
+	}
+
+	var rules []s3types.LifecycleRule
+	managed := false
+
+	out, err := client.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "NoSuchLifecycleConfiguration" {
+			return fmt.Errorf("get lifecycle configuration of %s: %w", bucket, err)
+		}
+	} else {
+		for _, r := range out.Rules {
+			if aws.ToString(r.ID) == s3LifecycleRuleID {
+				managed = true
+				continue
+			}
+			rules = append(rules, r)
+		}
+	}
+
+	if rule == nil && !managed {
+		return nil
+	}
+
+	if rule == nil {
+		helpers.Info("removing lifecycle rule %s from S3 bucket %s", s3LifecycleRuleID, bucket)
+	} else {
+		helpers.Info("applying lifecycle rule %s to S3 bucket %s", s3LifecycleRuleID, bucket)
+		rules = append(rules, *rule)
+	}
+
+	if len(rules) == 0 {
+		_, err = client.DeleteBucketLifecycle(ctx, &s3.DeleteBucketLifecycleInput{
+			Bucket: aws.String(bucket),
+		})
+		return err
+	}
+
+	_, err = client.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(bucket),
+		LifecycleConfiguration: &s3types.BucketLifecycleConfiguration{Rules: rules},
+	})
+	return err
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// s3PolicySidPrefix marks the bucket policy statements managed by this
+// provider; statements with other Sids are kept as they are.
+const s3PolicySidPrefix = "AwsComposeService"
+
+// s3PolicyStatement is kept as a generic map so statements written by others
+// round-trip without losing fields.
+type s3PolicyStatement map[string]any
+
+// s3Policy is a bucket policy document.
+type s3Policy struct {
+	Version   string              `json:"Version"`
+	ID        string              `json:"Id,omitempty"`
+	Statement []s3PolicyStatement `json:"Statement"`
+}
+
+// UnmarshalJSON accepts both a single statement object and a list of them.
+func (p *s3Policy) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Version   string          `json:"Version"`
+		ID        string          `json:"Id"`
+		Statement json.RawMessage `json:"Statement"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	p.Version = raw.Version
+	p.ID = raw.ID
+	p.Statement = nil
+
+	statement := bytes.TrimSpace(raw.Statement)
+	switch {
+	case len(statement) == 0:
+		return nil
+	case statement[0] == '{':
+		var s s3PolicyStatement
+		if err := json.Unmarshal(statement, &s); err != nil {
+			return err
+		}
+		p.Statement = []s3PolicyStatement{s}
+		return nil
+	default:
+		return json.Unmarshal(statement, &p.Statement)
+	}
+}
+
+// s3DenyInsecureTransportStatement denies every request made without TLS.
+func s3DenyInsecureTransportStatement(bucket string) s3PolicyStatement {
+	return s3PolicyStatement{
+		"Sid":       s3PolicySidPrefix + "DenyInsecureTransport",
+		"Effect":    "Deny",
+		"Principal": "*",
+		"Action":    "s3:*",
+		"Resource": []string{
+			"arn:aws:s3:::" + bucket,
+			"arn:aws:s3:::" + bucket + "/*",
+		},
+		"Condition": map[string]any{
+			"Bool": map[string]string{"aws:SecureTransport": "false"},
+		},
+	}
+}
+
+// s3ManagedPolicyStatements composes the statements the options ask for.
+func s3ManagedPolicyStatements(opt structs.Options, bucket string) []s3PolicyStatement {
+	var statements []s3PolicyStatement
+
+	if opt.EnforceTLS {
+		statements = append(statements, s3DenyInsecureTransportStatement(bucket))
+	}
+	if opt.PublicRead {
+		statements = append(statements, s3PublicReadStatement(bucket, opt.PublicReadPrefix))
+	}
+
+	return statements
+}
+
+// applyS3BucketPolicy replaces the managed statements of the bucket policy
+// with the composed ones, keeping statements added by others. The policy is
+// deleted when no statement is left.
+func applyS3BucketPolicy(ctx context.Context, client *s3.Client, opt structs.Options, bucket string) error {
+	managed := s3ManagedPolicyStatements(opt, bucket)
+
+	policy := s3Policy{Version: "2012-10-17"}
+	hadManaged := false
+
+	out, err := client.GetBucketPolicy(ctx, &s3.GetBucketPolicyInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "NoSuchBucketPolicy" {
+			return fmt.Errorf("get bucket policy of %s: %w", bucket, err)
+		}
+	} else {
+		var current s3Policy
+		if err := json.Unmarshal([]byte(aws.ToString(out.Policy)), &current); err != nil {
+			return fmt.Errorf("parse bucket policy of %s: %w", bucket, err)
+		}
+
+		policy.Version = helpers.WithFallbackValue(current.Version, policy.Version)
+		policy.ID = current.ID
+		for _, s := range current.Statement {
+			if sid, _ := s["Sid"].(string); strings.HasPrefix(sid, s3PolicySidPrefix) {
+				hadManaged = true
+				continue
+			}
+			policy.Statement = append(policy.Statement, s)
+		}
+	}
+
+	if len(managed) == 0 && !hadManaged {
+		return nil
+	}
+
+	policy.Statement = append(policy.Statement, managed...)
+
+	if len(policy.Statement) == 0 {
+		helpers.Info("removing bucket policy of S3 bucket %s", bucket)
+		_, err := client.DeleteBucketPolicy(ctx, &s3.DeleteBucketPolicyInput{
+			Bucket: aws.String(bucket),
+		})
+		return err
+	}
+
+	document, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+
+	helpers.Info("applying bucket policy to S3 bucket %s (%d managed statements)", bucket, len(managed))
+
+	_, err = client.PutBucketPolicy(ctx, &s3.PutBucketPolicyInput{
+		Bucket: aws.String(bucket),
+		Policy: aws.String(string(document)),
+	})
+	return err
+}
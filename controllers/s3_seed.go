@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"golang.org/x/sync/errgroup"
+)
+
+// s3ChecksumMetadataKey is the user metadata holding the SHA-256 of an uploaded
+// file. ETags cannot be compared for multipart uploads, so this is what tells
+// unchanged files apart.
+const s3ChecksumMetadataKey = "sha256"
+
+// seedKeyPrefix normalises seed_prefix into "" or "some/prefix/".
+func seedKeyPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// seedObjectKey maps a file under dir to its object key.
+func seedObjectKey(dir, file, prefix string) (string, error) {
+	rel, err := filepath.Rel(dir, file)
+	if err != nil {
+		return "", err
+	}
+	return seedKeyPrefix(prefix) + filepath.ToSlash(rel), nil
+}
+
+// detectContentType guesses a file's Content-Type from its extension, falling
+// back to sniffing its first bytes.
+func detectContentType(file string, f io.ReadSeeker) (string, error) {
+	if ct := mime.TypeByExtension(path.Ext(file)); ct != "" {
+		return ct, nil
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
+
+// fileSHA256 returns the hex SHA-256 of a file and rewinds it.
+func fileSHA256(f io.ReadSeeker) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// uploadS3File uploads a local file unless the object already carries the same
+// checksum. It reports whether the file was uploaded.
+func uploadS3File(ctx context.Context, client *s3.Client, uploader *manager.Uploader, bucket, key, file string) (bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	sum, err := fileSHA256(f)
+	if err != nil {
+		return false, fmt.Errorf("checksum %s: %w", file, err)
+	}
+
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err == nil && head.Metadata[s3ChecksumMetadataKey] == sum {
+		return false, nil
+	}
+
+	contentType, err := detectContentType(file, f)
+	if err != nil {
+		return false, fmt.Errorf("detect content type of %s: %w", file, err)
+	}
+
+	_, err = uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        f,
+		ContentType: aws.String(contentType),
+		Metadata:    map[string]string{s3ChecksumMetadataKey: sum},
+	})
+	if err != nil {
+		return false, fmt.Errorf("upload %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// seedS3Bucket syncs the files under dir into the bucket (below prefix),
+// uploading concurrently and skipping objects whose checksum is unchanged.
+func seedS3Bucket(ctx context.Context, client *s3.Client, bucket, dir, prefix string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("seed_dir %s is not a directory", dir)
+	}
+
+	helpers.Info("seeding S3 bucket %s from %s (prefix=%q)", bucket, dir, seedKeyPrefix(prefix))
+
+	uploader := newS3Uploader(client)
+
+	var uploaded, skipped atomic.Int64
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(s3TransferConcurrency)
+
+	walkErr := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if gctx.Err() != nil {
+			// An upload failed; g.Wait reports it
+			return filepath.SkipAll
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		key, err := seedObjectKey(dir, file, prefix)
+		if err != nil {
+			return err
+		}
+
+		g.Go(func() error {
+			changed, err := uploadS3File(gctx, client, uploader, bucket, key, file)
+			if err != nil {
+				return err
+			}
+			if changed {
+				helpers.Debug("uploaded %s to s3://%s/%s", file, bucket, key)
+				uploaded.Add(1)
+			} else {
+				skipped.Add(1)
+			}
+			return nil
+		})
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	if walkErr != nil {
+		return walkErr
+	}
+
+	helpers.Info("seeded S3 bucket %s: %d files uploaded, %d unchanged", bucket, uploaded.Load(), skipped.Load())
+	return nil
+}
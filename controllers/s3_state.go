@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// headS3Bucket reports whether the bucket exists and is ours to use in region.
+// HeadBucket has no error body, so the outcome is read from the status code:
+// 404 means the name is free, 403 that another account owns the bucket (or
+// the credentials may not list it) and 301 that it lives in another region.
+func headS3Bucket(ctx context.Context, client *s3.Client, bucket, region string) (bool, error) {
+	out, err := client.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(bucket),
+	})
+	if err == nil {
+		if other := aws.ToString(out.BucketRegion); other != "" && other != region {
+			return true, fmt.Errorf("S3 bucket %s exists in %s, not %s; set region to %s or choose another bucket_name", bucket, other, region, other)
+		}
+		return true, nil
+	}
+
+	var respErr *awshttp.ResponseError
+	if !errors.As(err, &respErr) {
+		return false, fmt.Errorf("head S3 bucket %s: %w", bucket, err)
+	}
+
+	switch respErr.HTTPStatusCode() {
+	case http.StatusNotFound:
+		return false, nil
+	case http.StatusForbidden:
+		return false, fmt.Errorf("S3 bucket %s exists but access is denied (403): it is owned by another account or the credentials lack s3:ListBucket; choose another bucket_name", bucket)
+	case http.StatusMovedPermanently:
+		other := respErr.Response.Header.Get("X-Amz-Bucket-Region")
+		return false, fmt.Errorf("S3 bucket %s exists in %s, not %s; set region to %s or choose another bucket_name", bucket, other, region, other)
+	default:
+		return false, fmt.Errorf("head S3 bucket %s: %w", bucket, err)
+	}
+}
+
+// isNoSuchBucket reports whether err says the bucket does not exist. Most S3
+// operations return it as a generic API error rather than *s3types.NoSuchBucket.
+func isNoSuchBucket(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchBucket"
+}
+
+// s3AdoptedTag marks buckets that existed before this provider took them over
+// with adopt_bucket, so their access settings are left alone unless asked.
+const s3AdoptedTag = "aws-compose-service:adopted"
+
+// s3BucketTags returns the bucket's tags; a bucket without tags is not an error.
+func s3BucketTags(ctx context.Context, client *s3.Client, bucket string) (map[string]string, error) {
+	out, err := client.GetBucketTagging(ctx, &s3.GetBucketTaggingInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchTagSet" {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+
+	tags := make(map[string]string, len(out.TagSet))
+	for _, t := range out.TagSet {
+		tags[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	return tags, nil
+}
+
+// putS3BucketTags replaces the bucket's tag set.
+func putS3BucketTags(ctx context.Context, client *s3.Client, bucket string, tags map[string]string) error {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tagSet := make([]s3types.Tag, 0, len(keys))
+	for _, k := range keys {
+		tagSet = append(tagSet, s3types.Tag{Key: aws.String(k), Value: aws.String(tags[k])})
+	}
+
+	_, err := client.PutBucketTagging(ctx, &s3.PutBucketTaggingInput{
+		Bucket:  aws.String(bucket),
+		Tagging: &s3types.Tagging{TagSet: tagSet},
+	})
+	return err
+}
+
+// s3OwnershipConflict refuses buckets tagged for another project or service.
+func s3OwnershipConflict(bucket string, tags map[string]string) error {
+	_, hasProject := tags[ownerProjectTag]
+	_, hasService := tags[ownerServiceTag]
+	if hasProject || hasService {
+		return fmt.Errorf(
+			"S3 bucket %s belongs to project %q / service %q; choose another bucket_name",
+			bucket,
+			tags[ownerProjectTag],
+			tags[ownerServiceTag],
+		)
+	}
+	return nil
+}
+
+// checkS3BucketOwner refuses to touch the contents of a bucket that does not
+// carry the service's ownership tags, whether it is tagged for another project
+// or service or not tagged at all. Unlike ensureS3BucketOwned it never adds tags.
+func checkS3BucketOwner(ctx context.Context, client *s3.Client, opt structs.Options, bucket string) error {
+	tags, err := s3BucketTags(ctx, client, bucket)
+	if err != nil {
+		return fmt.Errorf("get tags of S3 bucket %s: %w", bucket, err)
+	}
+
+	want := ownershipTagValues(opt, "s3")
+	if ownedBy(tags, want) {
+		return nil
+	}
+
+	if err := s3OwnershipConflict(bucket, tags); err != nil {
+		return err
+	}
+	return fmt.Errorf(
+		"S3 bucket %s is not tagged as belonging to project %q / service %q; refusing to modify its contents (run up with adopt_bucket: true to take it over)",
+		bucket,
+		want[ownerProjectTag],
+		want[ownerServiceTag],
+	)
+}
+
+// ensureS3BucketOwned verifies that an existing bucket carries the service's
+// ownership tags. Buckets tagged for another project or service are refused;
+// untagged buckets are refused too, unless adopt_bucket explicitly asks to
+// take them over by adding the tags.
+func ensureS3BucketOwned(ctx context.Context, client *s3.Client, opt structs.Options, bucket string) error {
+	tags, err := s3BucketTags(ctx, client, bucket)
+	if err != nil {
+		return fmt.Errorf("get tags of S3 bucket %s: %w", bucket, err)
+	}
+
+	want := ownershipTagValues(opt, "s3")
+	if ownedBy(tags, want) {
+		return nil
+	}
+
+	if err := s3OwnershipConflict(bucket, tags); err != nil {
+		return err
+	}
+
+	if !opt.AdoptBucket {
+		return fmt.Errorf(
+			"S3 bucket %s exists but is not tagged as belonging to project %q / service %q; set adopt_bucket: true to take it over, or choose another bucket_name",
+			bucket,
+			want[ownerProjectTag],
+			want[ownerServiceTag],
+		)
+	}
+
+	helpers.Warn("adopting untagged S3 bucket %s for %s / %s (adopt_bucket)", bucket, want[ownerProjectTag], want[ownerServiceTag])
+
+	for k, v := range want {
+		tags[k] = v
+	}
+	tags[s3AdoptedTag] = "true"
+	if err := putS3BucketTags(ctx, client, bucket, tags); err != nil {
+		return fmt.Errorf("tag S3 bucket %s: %w", bucket, err)
+	}
+	return nil
+}
+
+// ensureS3Bucket creates the bucket when it is missing and verifies ownership
+// when it is reused, so repeated runs of up converge on the same bucket. It
+// reports whether the bucket was created by this call.
+func ensureS3Bucket(ctx context.Context, client *s3.Client, opt structs.Options, bucket, region string) (bool, error) {
+	exists, err := headS3Bucket(ctx, client, bucket, region)
+	if err != nil {
+		return false, err
+	}
+
+	if exists {
+		helpers.Info("reusing S3 bucket %s in region %s", bucket, region)
+		return false, ensureS3BucketOwned(ctx, client, opt, bucket)
+	}
+
+	helpers.Info("creating S3 bucket %s in region %s", bucket, region)
+
+	err = createS3Bucket(ctx, client, bucket, region)
+	if err != nil {
+		var ownedByYou *s3types.BucketAlreadyOwnedByYou
+		var alreadyExists *s3types.BucketAlreadyExists
+
+		switch {
+		case errors.As(err, &ownedByYou):
+			// Created concurrently by another run in this account
+			helpers.Info("S3 bucket %s already exists in this account, reusing it", bucket)
+			return false, ensureS3BucketOwned(ctx, client, opt, bucket)
+		case errors.As(err, &alreadyExists):
+			return false, fmt.Errorf("S3 bucket name %s is taken by another account (bucket names are global); choose another bucket_name", bucket)
+		default:
+			return false, fmt.Errorf("create S3 bucket %s: %w", bucket, err)
+		}
+	}
+
+	if err := putS3BucketTags(ctx, client, bucket, ownershipTagValues(opt, "s3")); err != nil {
+		return false, fmt.Errorf("tag S3 bucket %s: %w", bucket, err)
+	}
+	return true, nil
+}
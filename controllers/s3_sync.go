@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/fsnotify/fsnotify"
+)
+
+// deleteS3Key deletes an object and everything below key + "/", which covers
+// both removed files and removed directories. It returns how many objects
+// were deleted, so short-lived files that never made it to S3 are silent.
+func deleteS3Key(ctx context.Context, client *s3.Client, bucket, key string) (int, error) {
+	var objects []s3types.ObjectIdentifier
+
+	_, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err == nil {
+		objects = append(objects, s3types.ObjectIdentifier{Key: aws.String(key)})
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(key + "/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return 0, err
+		}
+		for _, o := range page.Contents {
+			objects = append(objects, s3types.ObjectIdentifier{Key: o.Key})
+		}
+	}
+
+	for start := 0; start < len(objects); start += s3DeleteBatchSize {
+		end := min(start+s3DeleteBatchSize, len(objects))
+		if err := deleteS3ObjectBatch(ctx, client, bucket, objects[start:end]); err != nil {
+			return 0, err
+		}
+	}
+	return len(objects), nil
+}
+
+// addWatchTree watches dir and every directory below it; fsnotify is not recursive.
+func addWatchTree(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(p)
+		}
+		return nil
+	})
+}
+
+// syncS3Path brings one changed local path in line with the bucket: files are
+// uploaded (unless unchanged), new directories are watched and uploaded, and
+// paths that no longer exist are deleted.
+func syncS3Path(ctx context.Context, client *s3.Client, uploader *manager.Uploader, watcher *fsnotify.Watcher, bucket, dir, prefix, file string) error {
+	key, err := seedObjectKey(dir, file, prefix)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Lstat(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		n, err := deleteS3Key(ctx, client, bucket, key)
+		if err != nil {
+			return fmt.Errorf("delete %s: %w", key, err)
+		}
+		if n > 0 {
+			helpers.Event("deleted s3://%s/%s", bucket, key)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		if err := addWatchTree(watcher, file); err != nil {
+			return fmt.Errorf("watch %s: %w", file, err)
+		}
+		return filepath.WalkDir(file, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || !d.Type().IsRegular() {
+				return err
+			}
+			return syncS3Path(ctx, client, uploader, watcher, bucket, dir, prefix, p)
+		})
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	uploaded, err := uploadS3File(ctx, client, uploader, bucket, key, file)
+	if err != nil {
+		return err
+	}
+	if uploaded {
+		helpers.Event("uploaded %s to s3://%s/%s", file, bucket, key)
+	}
+	return nil
+}
+
+// SyncS3 uploads seed_dir into the service's bucket and, with watch set, keeps
+// syncing changes (uploads and deletes) until ctx is cancelled. Bursts of file
+// events are debounced so that editors saving several times sync once.
+func SyncS3(ctx context.Context, opt structs.Options) error {
+	region := helpers.WithFallbackValue(opt.Region, "ap-southeast-1")
+	bucket := deriveBucketName(opt, region)
+
+	if opt.SeedDir == "" {
+		helpers.Error("sync needs --seed_dir")
+		return fmt.Errorf("no seed_dir specified")
+	}
+
+	dir, err := filepath.Abs(opt.SeedDir)
+	if err != nil {
+		helpers.Error("resolve seed_dir failed: %v", err)
+		return err
+	}
+
+	cfg, err := helpers.LoadAWSConfig(ctx, region)
+	if err != nil {
+		helpers.Error("unable to load AWS config: %v", err)
+		return err
+	}
+
+	client := s3.NewFromConfig(cfg)
+
+	if err := checkS3BucketOwner(ctx, client, opt, bucket); err != nil {
+		helpers.Error("%v", err)
+		return err
+	}
+
+	if err := seedS3Bucket(ctx, client, bucket, dir, opt.SeedPrefix); err != nil {
+		helpers.Error("sync to S3 bucket %s failed: %v", bucket, err)
+		return err
+	}
+
+	if !opt.SyncWatch {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		helpers.Error("start file watcher failed: %v", err)
+		return err
+	}
+	defer watcher.Close()
+
+	if err := addWatchTree(watcher, dir); err != nil {
+		helpers.Error("watch %s failed: %v", dir, err)
+		return err
+	}
+
+	debounce := time.Duration(opt.SyncDebounce) * time.Millisecond
+	if debounce <= 0 {
+		debounce = 300 * time.Millisecond
+	}
+
+	helpers.Info("watching %s for changes to sync into s3://%s/%s (debounce %s)", dir, bucket, seedKeyPrefix(opt.SeedPrefix), debounce)
+
+	uploader := newS3Uploader(client)
+	pending := map[string]bool{}
+
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op == fsnotify.Chmod {
+				continue
+			}
+			pending[event.Name] = true
+			timer.Reset(debounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			helpers.Error("file watcher error: %v", err)
+
+		case <-timer.C:
+			for file := range pending {
+				if err := syncS3Path(ctx, client, uploader, watcher, bucket, dir, opt.SeedPrefix, file); err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					// Keep watching; the next change to the file retries it
+					helpers.Error("sync %s failed: %v", file, err)
+				}
+			}
+			pending = map[string]bool{}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+)
+
+// ParseSnapshotCommand routes a "snapshot <action>" call to the proper service implementation.
+func ParseSnapshotCommand(ctx context.Context, opt structs.Options, action string) error {
+	service := strings.ToLower(helpers.WithFallbackValue(opt.Service, "rds"))
+
+	if service != "rds" {
+		helpers.Error("unsupported service for snapshot: %s (expected: rds)", service)
+		return fmt.Errorf("snapshot is not supported for service %s", service)
+	}
+
+	switch action {
+	case "create":
+		return RDSSnapshotCreate(ctx, opt)
+	case "list":
+		return RDSSnapshotList(ctx, opt)
+	case "restore":
+		return RDSSnapshotRestore(ctx, opt)
+	case "delete":
+		return RDSSnapshotDelete(ctx, opt)
+	default:
+		helpers.Error("unsupported snapshot action: %s (expected: create, list, restore or delete)", action)
+		return fmt.Errorf("unsupported snapshot action %q", action)
+	}
+}
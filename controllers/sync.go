@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+)
+
+// ParseSyncCommand routes the "sync" call to the proper service implementation.
+func ParseSyncCommand(ctx context.Context, opt structs.Options) error {
+	service := strings.ToLower(helpers.WithFallbackValue(opt.Service, "s3"))
+
+	switch service {
+	case "s3":
+		return SyncS3(ctx, opt)
+	default:
+		helpers.Error("unsupported service for sync: %s (expected: s3)", service)
+		return fmt.Errorf("sync is not supported for service %s", service)
+	}
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+)
+
+// Ownership tags mark the resources created for a Compose project / service.
+const (
+	ownerProjectTag = "aws-compose-service:project"
+	ownerServiceTag = "aws-compose-service:service"
+)
+
+// ownershipTagValues returns the project / service pair resources are tagged with.
+func ownershipTagValues(opt structs.Options, fallbackName string) map[string]string {
+	return map[string]string{
+		ownerProjectTag: helpers.WithFallbackValue(opt.Project, "compose"),
+		ownerServiceTag: helpers.WithFallbackValue(opt.Name, fallbackName),
+	}
+}
+
+// ownedBy reports whether the tags carry the expected ownership values.
+func ownedBy(tags map[string]string, want map[string]string) bool {
+	for k, v := range want {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+)
+
+// ParseUpgradeCommand routes the "upgrade" call to the proper service implementation.
+func ParseUpgradeCommand(ctx context.Context, opt structs.Options) error {
+	service := strings.ToLower(helpers.WithFallbackValue(opt.Service, "rds"))
+
+	switch service {
+	case "rds":
+		return RDSUpgrade(ctx, opt)
+	default:
+		helpers.Error("unsupported service for upgrade: %s (expected: rds)", service)
+		return fmt.Errorf("upgrade is not supported for service %s", service)
+	}
+}
@@ -0,0 +1,23 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/InspectorGadget/aws-compose-service/helpers"
+	"github.com/InspectorGadget/aws-compose-service/structs"
+)
+
+// ParseWatchCommand routes the "watch" call to the proper service implementation.
+func ParseWatchCommand(ctx context.Context, opt structs.Options) error {
+	service := strings.ToLower(helpers.WithFallbackValue(opt.Service, "rds"))
+
+	switch service {
+	case "rds":
+		return WatchRDSEvents(ctx, opt)
+	default:
+		helpers.Error("unsupported service for watch: %s (expected: rds)", service)
+		return fmt.Errorf("watch is not supported for service %s", service)
+	}
+}
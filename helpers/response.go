@@ -28,6 +28,12 @@ func Debug(format string, args ...any) {
 	send("debug", fmt.Sprintf(format, args...))
 }
 
+// Warn emits an informational message flagged as a warning. Compose treats
+// "error" messages as failures, so warnings travel as "info".
+func Warn(format string, args ...any) {
+	send("info", "WARNING: "+fmt.Sprintf(format, args...))
+}
+
 // Error emits an error message (but does not exit).
 func Error(format string, args ...any) {
 	send("error", fmt.Sprintf(format, args...))
@@ -37,3 +43,8 @@ func Error(format string, args ...any) {
 func Setenv(key, value string) {
 	send("setenv", fmt.Sprintf("%s=%s", key, value))
 }
+
+// Event emits an event observed on a managed resource, e.g. by watch.
+func Event(format string, args ...any) {
+	send("event", fmt.Sprintf(format, args...))
+}
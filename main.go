@@ -37,15 +37,25 @@ func newRootCommand(ctx context.Context) *cobra.Command {
 	composeCmd.AddCommand(
 		commands.NewUpCommand(ctx, opt),
 		commands.NewDownCommand(ctx, opt),
+		commands.NewUpgradeCommand(ctx, opt),
 	)
 
 	// Also allow direct usage:
 	//   aws-compose-service up ...
 	//   aws-compose-service down ...
+	//   aws-compose-service upgrade ...
+	//   aws-compose-service watch ...
+	//   aws-compose-service snapshot ...
+	//   aws-compose-service sync ...
 	root.AddCommand(
 		composeCmd,
 		commands.NewUpCommand(ctx, opt),
 		commands.NewDownCommand(ctx, opt),
+		commands.NewUpgradeCommand(ctx, opt),
+		commands.NewWatchCommand(ctx, opt),
+		commands.NewSnapshotCommand(ctx, opt),
+		commands.NewSyncCommand(ctx, opt),
+		commands.NewTunnelCommand(ctx, opt),
 	)
 
 	return root
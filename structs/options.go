@@ -14,9 +14,17 @@ type Options struct {
 	Engine        string
 	EngineVersion string
 
+	// Engine-specific settings (Oracle, Db2, SQL Server editions)
+	LicenseModel    string
+	OracleConnectBy string
+
 	InstanceClass    string
 	AllocatedStorage int
 
+	// Instance class validation against DescribeOrderableDBInstanceOptions
+	ValidateInstanceClass bool
+	OrderableSnapshot     string
+
 	DBName   string
 	Username string
 	Password string
@@ -28,6 +36,117 @@ type Options struct {
 	PubliclyAccessible bool
 	MultiAZ            bool
 
+	// RDS backup, maintenance and protection settings
+	BackupRetentionPeriod      int
+	PreferredBackupWindow      string
+	PreferredMaintenanceWindow string
+	CopyTagsToSnapshot         bool
+	AutoMinorVersionUpgrade    bool
+	DeletionProtection         bool
+
+	// RDS observability
+	PerformanceInsights          bool
+	PerformanceInsightsRetention int
+	PerformanceInsightsKMSKeyID  string
+	MonitoringInterval           int
+	MonitoringRoleARN            string
+	LogExports                   []string
+
+	// RDS TLS: server certificate CA, CA bundle export and verified DSNs
+	CACertificateIdentifier string
+	RequireTLS              bool
+	CABundlePath            string
+
+	// RDS event subscription and the watch command
+	Events            []string
+	EventsSNSTopicARN string
+	WatchInterval     int
+	WatchSince        int
+
+	// RDS shared-instance mode: a logical database and app role per service
+	SharedInstance string
+	AppUsername    string
+	AppPassword    string
+
+	// RDS SSH bastion tunnel for private instances
+	TunnelHost            string
+	TunnelPort            int
+	TunnelUser            string
+	TunnelKey             string
+	TunnelKnownHosts      string
+	TunnelInsecureHostKey bool
+	TunnelLocalPort       int
+	TunnelBindAddress     string
+	TunnelExportHost      string
+
+	// RDS connectivity readiness probe
+	ReadinessCheck    string
+	ReadinessTimeout  int
+	ReadinessInterval int
+
+	// RDS schema migrations and seed data
+	InitSQLDir string
+	SeedFiles  []string
+
+	// RDS Blue/Green upgrades
+	DBParameterGroup  string
+	SwitchoverTimeout int
+
+	// RDS snapshot management
+	SnapshotID   string
+	KeepPrevious bool
+
+	// RDS drift handling for reused instances
+	Reconcile        bool
+	ApplyImmediately bool
+
+	// RDS teardown behaviour: stop, delete or snapshot
+	DownAction string
+	Force      bool
+
+	// Cross-region disaster recovery (rds: read replica or backup replication, s3: replication)
+	DRRegion string
+	DRMode   string
+
 	// S3-specific configuration
-	BucketName string
+	BucketName   string
+	AdoptBucket  bool
+	EmptyOnDown  bool
+	BackupOnDown string
+	RestoreFrom  string
+	SeedDir      string
+	SeedPrefix   string
+
+	// S3 default encryption and TLS-only bucket policy
+	Encryption string
+	KMSKeyID   string
+	DRKMSKeyID string
+	BucketKey  bool
+	EnforceTLS bool
+
+	// S3 public access (blocked unless public_read is set)
+	PublicRead         bool
+	PublicReadPrefix   string
+	ManagePublicAccess bool
+
+	// S3 CORS rule (defaults derived from FrontendOrigin)
+	FrontendOrigin     string
+	CORSAllowedOrigins []string
+	CORSAllowedMethods []string
+	CORSAllowedHeaders []string
+	CORSExposeHeaders  []string
+	CORSMaxAge         int
+
+	// S3 versioning and lifecycle rule
+	Versioning                        string
+	LifecycleExpirationDays           int
+	LifecycleNoncurrentExpirationDays int
+	LifecycleTransitions              []string
+	LifecycleAbortMultipartDays       int
+	LifecyclePrefix                   string
+	LifecycleTags                     []string
+
+	// S3 sync command
+	SyncWatch    bool
+	SyncDebounce int
 }